@@ -0,0 +1,286 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"splitwise/main/internal/auth"
+	"splitwise/main/internal/blobstore"
+	"splitwise/main/internal/db"
+	"splitwise/main/internal/thumbnail"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxAttachmentSize = 10 << 20
+	thumbnailSize     = 256
+)
+
+// attachmentTypes are the files that may be attached, keyed by the content
+// type sniffed from the upload, with the extension their blob is stored under
+var attachmentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"application/pdf": ".pdf",
+}
+
+// ExpenseAttachments handles /api/expenses/attachments: GET lists an
+// expense's attachments, POST uploads one as the multipart field "file" and
+// DELETE removes one
+func (h *Handler) ExpenseAttachments(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.getExpenseAttachments(w, r)
+	case http.MethodPost:
+		h.uploadAttachment(w, r)
+	case http.MethodDelete:
+		h.deleteAttachment(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// authorizeExpense loads an expense and checks the session user belongs to
+// its group, writing the error response and returning nil if not
+func authorizeExpense(w http.ResponseWriter, r *http.Request, expenseID string) *db.ExpenseRecord {
+	session := auth.GetUserFromRequest(r)
+	if session == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil
+	}
+	if expenseID == "" {
+		http.Error(w, "Expense ID required", http.StatusBadRequest)
+		return nil
+	}
+
+	expense, err := db.GetExpenseByID(expenseID)
+	if err != nil {
+		http.Error(w, "Expense not found", http.StatusNotFound)
+		return nil
+	}
+	if !db.IsUserInGroup(session.UserID, expense.GroupID) {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return nil
+	}
+	return expense
+}
+
+// authorizeAttachment is authorizeExpense for the expense an attachment
+// belongs to
+func authorizeAttachment(w http.ResponseWriter, r *http.Request) *db.AttachmentRecord {
+	attachmentID := r.URL.Query().Get("attachment_id")
+	if attachmentID == "" {
+		http.Error(w, "Attachment ID required", http.StatusBadRequest)
+		return nil
+	}
+
+	attachment, err := db.GetAttachment(attachmentID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Attachment not found", http.StatusNotFound)
+		return nil
+	}
+	if err != nil {
+		http.Error(w, "Failed to get attachment", http.StatusInternalServerError)
+		return nil
+	}
+
+	if authorizeExpense(w, r, attachment.ExpenseID) == nil {
+		return nil
+	}
+	return attachment
+}
+
+func (h *Handler) getExpenseAttachments(w http.ResponseWriter, r *http.Request) {
+	expense := authorizeExpense(w, r, r.URL.Query().Get("expense_id"))
+	if expense == nil {
+		return
+	}
+	sendJSON(w, expense.Attachments)
+}
+
+// uploadAttachment stores a receipt photo or PDF of at most 10 MB. The type
+// is sniffed from the file itself rather than trusted from the client, and
+// images get a thumbnail.
+func (h *Handler) uploadAttachment(w http.ResponseWriter, r *http.Request) {
+	expense := authorizeExpense(w, r, r.URL.Query().Get("expense_id"))
+	if expense == nil {
+		return
+	}
+	if expense.DeletedAt != nil {
+		http.Error(w, "Expense is deleted; restore it first", http.StatusConflict)
+		return
+	}
+
+	// Leave room for the multipart framing around the file
+	r.Body = http.MaxBytesReader(w, r.Body, maxAttachmentSize+1<<20)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			sendValidationErrors(w, map[string]string{"file": "must be at most 10 MB"})
+			return
+		}
+		sendValidationErrors(w, map[string]string{"file": "is required"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxAttachmentSize+1))
+	if err != nil {
+		http.Error(w, "Failed to read upload", http.StatusBadRequest)
+		return
+	}
+	if len(data) == 0 {
+		sendValidationErrors(w, map[string]string{"file": "is empty"})
+		return
+	}
+	if len(data) > maxAttachmentSize {
+		sendValidationErrors(w, map[string]string{"file": "must be at most 10 MB"})
+		return
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, ok := attachmentTypes[contentType]
+	if !ok {
+		sendValidationErrors(w, map[string]string{"file": "must be a JPEG, PNG or GIF image or a PDF"})
+		return
+	}
+
+	var thumb []byte
+	if strings.HasPrefix(contentType, "image/") {
+		thumb, err = thumbnail.Generate(data, thumbnailSize)
+		if errors.Is(err, thumbnail.ErrTooLarge) {
+			sendValidationErrors(w, map[string]string{"file": err.Error()})
+			return
+		}
+		if err != nil {
+			sendValidationErrors(w, map[string]string{"file": "could not be read as an image"})
+			return
+		}
+	}
+
+	session := auth.GetUserFromRequest(r)
+	attachmentID := auth.GenerateUserID()
+	attachment := &db.AttachmentRecord{
+		AttachmentID: attachmentID,
+		ExpenseID:    expense.ExpenseID,
+		FileName:     attachmentFileName(header.Filename, ext),
+		ContentType:  contentType,
+		SizeBytes:    int64(len(data)),
+		BlobKey:      "expenses/" + expense.ExpenseID + "/" + attachmentID + ext,
+		UploadedBy:   session.UserID,
+	}
+	if thumb != nil {
+		attachment.ThumbnailKey = "expenses/" + expense.ExpenseID + "/" + attachmentID + "-thumb.jpg"
+	}
+
+	if err := h.blobs.Put(attachment.BlobKey, bytes.NewReader(data)); err != nil {
+		http.Error(w, "Failed to store attachment: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if thumb != nil {
+		if err := h.blobs.Put(attachment.ThumbnailKey, bytes.NewReader(thumb)); err != nil {
+			h.deleteAttachmentBlobs(attachment)
+			http.Error(w, "Failed to store attachment: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := db.CreateAttachment(attachment); err != nil {
+		h.deleteAttachmentBlobs(attachment)
+		http.Error(w, "Failed to store attachment: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if stored, err := db.GetAttachment(attachmentID); err == nil {
+		attachment = stored
+	}
+	sendJSON(w, attachment)
+}
+
+// attachmentFileName keeps the base name of an uploaded file for display and
+// downloads, falling back to a generic name
+func attachmentFileName(name, ext string) string {
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+	if name == "" || name == "." || name == "/" {
+		return "receipt" + ext
+	}
+	if len(name) > 255 {
+		name = strings.ToValidUTF8(name[:255], "")
+	}
+	return name
+}
+
+func (h *Handler) deleteAttachment(w http.ResponseWriter, r *http.Request) {
+	attachment := authorizeAttachment(w, r)
+	if attachment == nil {
+		return
+	}
+
+	if err := db.DeleteAttachment(attachment.AttachmentID); err != nil {
+		http.Error(w, "Failed to delete attachment: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.deleteAttachmentBlobs(attachment)
+
+	sendJSON(w, map[string]string{"status": "deleted", "attachment_id": attachment.AttachmentID})
+}
+
+// deleteAttachmentBlobs removes an attachment's file and thumbnail. A blob
+// left behind is only wasted space, so failures are not reported.
+func (h *Handler) deleteAttachmentBlobs(attachment *db.AttachmentRecord) {
+	h.blobs.Delete(attachment.BlobKey)
+	if attachment.ThumbnailKey != "" {
+		h.blobs.Delete(attachment.ThumbnailKey)
+	}
+}
+
+// DownloadAttachment handles GET /api/attachments/download?attachment_id=,
+// serving the file, or its thumbnail with &thumbnail=true, to members of the
+// expense's group
+func (h *Handler) DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	attachment := authorizeAttachment(w, r)
+	if attachment == nil {
+		return
+	}
+
+	key, contentType, fileName := attachment.BlobKey, attachment.ContentType, attachment.FileName
+	if r.URL.Query().Get("thumbnail") == "true" {
+		if attachment.ThumbnailKey == "" {
+			http.Error(w, "Attachment has no thumbnail", http.StatusNotFound)
+			return
+		}
+		key, contentType = attachment.ThumbnailKey, "image/jpeg"
+		fileName = strings.TrimSuffix(fileName, filepath.Ext(fileName)) + "-thumb.jpg"
+	}
+
+	blob, err := h.blobs.Get(key)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		http.Error(w, "Attachment file is missing", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to read attachment", http.StatusInternalServerError)
+		return
+	}
+	defer blob.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": fileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	if key == attachment.BlobKey {
+		w.Header().Set("Content-Length", strconv.FormatInt(attachment.SizeBytes, 10))
+	}
+	io.Copy(w, blob)
+}
@@ -1,19 +1,33 @@
 package api
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"sort"
 	"splitwise/main/internal/auth"
+	"splitwise/main/internal/balancesheet"
+	"splitwise/main/internal/blobstore"
 	"splitwise/main/internal/db"
 	"splitwise/main/internal/entity"
 	"splitwise/main/internal/stragegy"
+	"strings"
 	"time"
 )
 
-type Handler struct{}
+type Handler struct {
+	// blobs holds expense attachments
+	blobs blobstore.Store
+}
+
+// dateLayout is how calendar dates are exchanged with clients
+const dateLayout = "2006-01-02"
 
-func NewHandler() *Handler {
-	return &Handler{}
+func NewHandler(blobs blobstore.Store) *Handler {
+	return &Handler{blobs: blobs}
 }
 
 // Request/Response types
@@ -46,40 +60,111 @@ const (
 type CreateGroupRequest struct {
 	GroupName string   `json:"group_name"`
 	MemberIDs []string `json:"member_ids"`
+	// BaseCurrency is what balances are kept in (default USD)
+	BaseCurrency string `json:"base_currency"`
 }
 
 type AddExpenseRequest struct {
-	ExpenseDescription string             `json:"expense_description"`
-	ExpenseAmount      float64            `json:"expense_amount"`
-	PaidByUserID       string             `json:"paid_by_user_id"`
-	GroupID            string             `json:"group_id"`
-	SplitType          string             `json:"split_type"`
-	SplitData          map[string]float64 `json:"split_data"`
+	ExpenseDescription string      `json:"expense_description"`
+	ExpenseAmount      json.Number `json:"expense_amount"`
+	// Currency of the amounts in the request (default: the group's base currency)
+	Currency     string             `json:"currency"`
+	PaidByUserID string             `json:"paid_by_user_id"`
+	GroupID      string             `json:"group_id"`
+	SplitType    string             `json:"split_type"`
+	SplitData    map[string]float64 `json:"split_data"`
+	// Participants limits an equal split to these group members (default: everyone)
+	Participants []string `json:"participants"`
+	// Items makes this an itemized receipt; each item is split on its own and
+	// tax and tip are shared in proportion to what each member ordered
+	Items []LineItemRequest `json:"items"`
+	Tax   json.Number       `json:"tax"`
+	Tip   json.Number       `json:"tip"`
+	// Payers lists contributions when several members paid; they must add up
+	// to the expense. Without it PaidByUserID paid the whole amount.
+	Payers []PayerRequest `json:"payers"`
+	// Category is a built-in or group category (default: suggested from the
+	// description)
+	Category string `json:"category"`
+	// ExpenseDate is the day the expense happened, as YYYY-MM-DD (default: today)
+	ExpenseDate string `json:"expense_date"`
+}
+
+type PayerRequest struct {
+	UserID string      `json:"user_id"`
+	Amount json.Number `json:"amount"`
+}
+
+type LineItemRequest struct {
+	Name            string             `json:"name"`
+	UnitPrice       json.Number        `json:"unit_price"`
+	Quantity        int64              `json:"quantity"`
+	AssignedUserIDs []string           `json:"assigned_user_ids"`
+	SplitType       string             `json:"split_type"`
+	SplitData       map[string]float64 `json:"split_data"`
+}
+
+// ValidationErrorResponse is the 400 body for rejected input, keyed by field
+type ValidationErrorResponse struct {
+	Message string            `json:"message"`
+	Errors  map[string]string `json:"errors"`
 }
 
 type SettleRequest struct {
-	GroupID  string  `json:"group_id"`
-	ToUserID string  `json:"to_user_id"`
-	Amount   float64 `json:"amount"`
+	GroupID  string      `json:"group_id"`
+	ToUserID string      `json:"to_user_id"`
+	Amount   json.Number `json:"amount"`
+	Note     string      `json:"note"`
+	Method   string      `json:"method"`
+	// Mode is "partial" (default), "exact" or "overpayment"
+	Mode string `json:"mode"`
+}
+
+// NetSettleRequest pays a counterparty across all shared groups; an empty
+// amount pays off the whole net balance
+type NetSettleRequest struct {
+	ToUserID string      `json:"to_user_id"`
+	Amount   json.Number `json:"amount"`
+	// Currency picks which balance to pay when the users share groups with
+	// different base currencies
+	Currency string `json:"currency"`
+	Note     string `json:"note"`
+	Method   string `json:"method"`
+}
+
+// ExchangeRateRequest sets how many units of ToCurrency one unit of
+// FromCurrency buys
+type ExchangeRateRequest struct {
+	FromCurrency string      `json:"from_currency"`
+	ToCurrency   string      `json:"to_currency"`
+	Rate         json.Number `json:"rate"`
+	// Date the rate applies from, as YYYY-MM-DD (default: today)
+	Date string `json:"date"`
+}
+
+// DisputeSettlementRequest explains why a settlement is being disputed
+type DisputeSettlementRequest struct {
+	Reason string `json:"reason"`
 }
 
 type ExpenseResponse struct {
-	ExpenseID          string  `json:"expense_id"`
-	ExpenseDescription string  `json:"expense_description"`
-	ExpenseAmount      float64 `json:"expense_amount"`
-	GroupID            string  `json:"group_id"`
-	GroupName          string  `json:"group_name"`
-	PaidByUserID       string  `json:"paid_by_user_id"`
-	PaidByUserName     string  `json:"paid_by_user_name"`
+	ExpenseID          string       `json:"expense_id"`
+	ExpenseDescription string       `json:"expense_description"`
+	ExpenseAmount      entity.Money `json:"expense_amount"`
+	GroupID            string       `json:"group_id"`
+	GroupName          string       `json:"group_name"`
+	PaidByUserID       string       `json:"paid_by_user_id"`
+	PaidByUserName     string       `json:"paid_by_user_name"`
 }
 
 type BalanceResponse struct {
-	GroupID      string  `json:"group_id"`
-	FromUserID   string  `json:"from_user_id"`
-	FromUserName string  `json:"from_user_name"`
-	ToUserID     string  `json:"to_user_id"`
-	ToUserName   string  `json:"to_user_name"`
-	Amount       float64 `json:"amount"`
+	GroupID      string          `json:"group_id"`
+	FromUserID   string          `json:"from_user_id"`
+	FromUserName string          `json:"from_user_name"`
+	ToUserID     string          `json:"to_user_id"`
+	ToUserName   string          `json:"to_user_name"`
+	Amount       entity.Money    `json:"amount"`
+	Currency     entity.Currency `json:"currency"`
 }
 
 // CORS middleware
@@ -90,7 +175,7 @@ func (h *Handler) EnableCORS(next http.HandlerFunc) http.HandlerFunc {
 			origin = "*"
 		}
 		w.Header().Set("Access-Control-Allow-Origin", origin)
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 		if r.Method == "OPTIONS" {
@@ -295,6 +380,14 @@ func (h *Handler) CreateGroup(w http.ResponseWriter, r *http.Request) {
 
 	groupID := auth.GenerateUserID()
 	group := entity.NewGroup(groupID, req.GroupName, members)
+	if req.BaseCurrency != "" {
+		currency, err := entity.ParseCurrency(req.BaseCurrency)
+		if err != nil {
+			sendValidationErrors(w, map[string]string{"base_currency": "must be a three letter currency code"})
+			return
+		}
+		group.BaseCurrency = currency
+	}
 
 	if err := db.CreateGroup(group, session.UserID); err != nil {
 		http.Error(w, "Failed to create group: "+err.Error(), http.StatusInternalServerError)
@@ -320,6 +413,21 @@ func (h *Handler) GetMyGroups(w http.ResponseWriter, r *http.Request) {
 	sendJSON(w, groups)
 }
 
+// GroupSettingsRequest changes the settings that are given, leaving the rest
+type GroupSettingsRequest struct {
+	GroupID       string `json:"group_id"`
+	SimplifyDebts *bool  `json:"simplify_debts"`
+	BaseCurrency  string `json:"base_currency"`
+}
+
+// CategoryRequest adds a custom category to a group. Keywords in a new
+// expense's description suggest it, ahead of the built-in rules.
+type CategoryRequest struct {
+	GroupID  string   `json:"group_id"`
+	Name     string   `json:"name"`
+	Keywords []string `json:"keywords"`
+}
+
 type AddMemberRequest struct {
 	GroupID string `json:"group_id"`
 	UserID  string `json:"user_id"`
@@ -378,140 +486,181 @@ func (h *Handler) GetGroupDetails(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get group expenses and balances
-	expenses, _ := db.GetGroupExpenses(groupID)
+	// Get group expenses, settlements and balances
+	expenses, _ := db.GetGroupExpenses(groupID, db.ExpenseFilter{})
+	settlements, _ := db.GetGroupSettlements(groupID)
 	balances, _ := db.GetGroupBalances(groupID)
 
 	sendJSON(w, map[string]interface{}{
-		"group":    group,
-		"expenses": expenses,
-		"balances": balances,
+		"group":       group,
+		"expenses":    expenses,
+		"settlements": settlements,
+		"balances":    balances,
 	})
 }
 
-// ============ EXPENSE ENDPOINTS ============
-
-func (h *Handler) AddExpense(w http.ResponseWriter, r *http.Request) {
+// GetGroupActivity returns the group's expenses and settlements interleaved,
+// newest first
+func (h *Handler) GetGroupActivity(w http.ResponseWriter, r *http.Request) {
 	session := auth.GetUserFromRequest(r)
 	if session == nil {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	var req AddExpenseRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	groupID := r.URL.Query().Get("group_id")
+	if groupID == "" {
+		http.Error(w, "Group ID required", http.StatusBadRequest)
 		return
 	}
 
 	// Verify user is in group
-	if !db.IsUserInGroup(session.UserID, req.GroupID) {
+	if !db.IsUserInGroup(session.UserID, groupID) {
 		http.Error(w, "Access denied", http.StatusForbidden)
 		return
 	}
 
-	// Get group for split calculation
-	group, err := db.GetGroupByID(req.GroupID)
+	activity, err := db.GetGroupActivity(groupID)
 	if err != nil {
-		http.Error(w, "Group not found", http.StatusBadRequest)
+		sendJSON(w, []db.ActivityRecord{})
 		return
 	}
 
-	// Convert split type string to enum
-	var splitType stragegy.SplitType
-	switch req.SplitType {
-	case "equal":
-		splitType = stragegy.Equal
-	case "percentage":
-		splitType = stragegy.Percentage
-	case "exact":
-		splitType = stragegy.Exact
-	default:
-		splitType = stragegy.Equal
-	}
-
-	// Convert split data from userID keys to User keys
-	splitData := make(map[entity.User]float64)
-	for userID, amount := range req.SplitData {
-		user, err := db.GetUserByID(userID)
-		if err == nil {
-			splitData[*user] = amount
-		}
-	}
-
-	// Calculate splits using strategy
-	splits := stragegy.GetSplitStrategy(splitType, group).CalculateSplits(splitData, req.ExpenseAmount)
-
-	// Determine who paid (use request value or fall back to session user)
-	paidByUserID := req.PaidByUserID
-	if paidByUserID == "" {
-		paidByUserID = session.UserID
-	}
+	sendJSON(w, activity)
+}
 
-	// Save expense to database
-	expenseID := auth.GenerateUserID()
-	if err := db.CreateExpense(expenseID, req.ExpenseDescription, req.ExpenseAmount, req.GroupID, paidByUserID, splits); err != nil {
-		http.Error(w, "Failed to add expense: "+err.Error(), http.StatusInternalServerError)
+// UpdateGroupSettings changes per-group display preferences
+func (h *Handler) UpdateGroupSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Update balances in database (per group)
-	for _, split := range splits {
-		if split.User.UserID != paidByUserID {
-			db.UpdateBalance(req.GroupID, paidByUserID, split.User.UserID, split.Amount)
-		}
-	}
-
-	sendJSON(w, map[string]string{"status": "created", "expense_id": expenseID})
-}
-
-func (h *Handler) GetGroupExpenses(w http.ResponseWriter, r *http.Request) {
 	session := auth.GetUserFromRequest(r)
 	if session == nil {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	groupID := r.URL.Query().Get("group_id")
-	if groupID == "" {
-		http.Error(w, "Group ID required", http.StatusBadRequest)
+	var req GroupSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
 	// Verify user is in group
-	if !db.IsUserInGroup(session.UserID, groupID) {
+	if !db.IsUserInGroup(session.UserID, req.GroupID) {
 		http.Error(w, "Access denied", http.StatusForbidden)
 		return
 	}
 
-	expenses, err := db.GetGroupExpenses(groupID)
+	if req.BaseCurrency != "" {
+		currency, err := entity.ParseCurrency(req.BaseCurrency)
+		if err != nil {
+			sendValidationErrors(w, map[string]string{"base_currency": "must be a three letter currency code"})
+			return
+		}
+		if err := db.SetGroupBaseCurrency(req.GroupID, currency); err != nil {
+			if errors.Is(err, db.ErrBaseCurrencyInUse) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			http.Error(w, "Failed to update settings: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if req.SimplifyDebts != nil {
+		if err := db.SetGroupSimplifyDebts(req.GroupID, *req.SimplifyDebts); err != nil {
+			http.Error(w, "Failed to update settings: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	group, err := db.GetGroupByID(req.GroupID)
 	if err != nil {
-		sendJSON(w, []ExpenseResponse{})
+		http.Error(w, "Group not found", http.StatusNotFound)
 		return
 	}
 
-	sendJSON(w, expenses)
+	sendJSON(w, map[string]interface{}{
+		"status":         "updated",
+		"simplify_debts": group.SimplifyDebts,
+		"base_currency":  group.BaseCurrency,
+	})
 }
 
-// ============ BALANCE/SETTLE ENDPOINTS ============
-
-func (h *Handler) GetMyBalanceSummary(w http.ResponseWriter, r *http.Request) {
+// GroupCategories handles GET and POST /api/groups/categories: listing the
+// categories a group's expenses can use, and adding a custom one
+func (h *Handler) GroupCategories(w http.ResponseWriter, r *http.Request) {
 	session := auth.GetUserFromRequest(r)
 	if session == nil {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	summary, err := db.GetUserBalanceSummary(session.UserID)
-	if err != nil {
-		http.Error(w, "Failed to get balance summary", http.StatusInternalServerError)
-		return
-	}
+	switch r.Method {
+	case http.MethodGet:
+		groupID := r.URL.Query().Get("group_id")
+		if !db.IsUserInGroup(session.UserID, groupID) {
+			http.Error(w, "Access denied", http.StatusForbidden)
+			return
+		}
 
-	sendJSON(w, summary)
+		categories, err := db.GetGroupCategories(groupID)
+		if err != nil {
+			http.Error(w, "Failed to get categories", http.StatusInternalServerError)
+			return
+		}
+		sendJSON(w, categories)
+
+	case http.MethodPost:
+		var req CategoryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if !db.IsUserInGroup(session.UserID, req.GroupID) {
+			http.Error(w, "Access denied", http.StatusForbidden)
+			return
+		}
+
+		fieldErrs := make(map[string]string)
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" || len(req.Name) > 30 {
+			fieldErrs["name"] = "must be between 1 and 30 characters"
+		}
+		keywords := make([]string, 0, len(req.Keywords))
+		for _, keyword := range req.Keywords {
+			if keyword = strings.TrimSpace(keyword); keyword != "" {
+				keywords = append(keywords, keyword)
+			}
+		}
+		if len(keywords) > 20 {
+			fieldErrs["keywords"] = "must not have more than 20 entries"
+		}
+		if len(fieldErrs) > 0 {
+			sendValidationErrors(w, fieldErrs)
+			return
+		}
+
+		if err := db.CreateGroupCategory(req.GroupID, req.Name, keywords); err != nil {
+			if errors.Is(err, db.ErrCategoryExists) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			http.Error(w, "Failed to create category: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sendJSON(w, map[string]string{"status": "created", "name": req.Name})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
 }
 
-func (h *Handler) GetGroupBalances(w http.ResponseWriter, r *http.Request) {
+// SuggestCategory handles GET /api/categories/suggest?group_id=&description=,
+// returning the category a new expense with that description would get
+func (h *Handler) SuggestCategory(w http.ResponseWriter, r *http.Request) {
 	session := auth.GetUserFromRequest(r)
 	if session == nil {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
@@ -519,46 +668,29 @@ func (h *Handler) GetGroupBalances(w http.ResponseWriter, r *http.Request) {
 	}
 
 	groupID := r.URL.Query().Get("group_id")
-	if groupID == "" {
-		http.Error(w, "Group ID required", http.StatusBadRequest)
-		return
-	}
-
-	// Verify user is in group
 	if !db.IsUserInGroup(session.UserID, groupID) {
 		http.Error(w, "Access denied", http.StatusForbidden)
 		return
 	}
 
-	balances, err := db.GetGroupBalances(groupID)
+	category, err := db.SuggestCategory(groupID, r.URL.Query().Get("description"))
 	if err != nil {
-		sendJSON(w, []BalanceResponse{})
+		http.Error(w, "Failed to suggest category", http.StatusInternalServerError)
 		return
 	}
-
-	response := make([]BalanceResponse, 0)
-	for _, b := range balances {
-		response = append(response, BalanceResponse{
-			GroupID:      b.GroupID,
-			FromUserID:   b.FromUserID,
-			FromUserName: b.FromUserName,
-			ToUserID:     b.ToUserID,
-			ToUserName:   b.ToUserName,
-			Amount:       b.Amount,
-		})
-	}
-
-	sendJSON(w, response)
+	sendJSON(w, map[string]string{"category": category})
 }
 
-func (h *Handler) Settle(w http.ResponseWriter, r *http.Request) {
+// ============ EXPENSE ENDPOINTS ============
+
+func (h *Handler) AddExpense(w http.ResponseWriter, r *http.Request) {
 	session := auth.GetUserFromRequest(r)
 	if session == nil {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	var req SettleRequest
+	var req AddExpenseRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
@@ -570,130 +702,1209 @@ func (h *Handler) Settle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := db.SettleBalance(req.GroupID, session.UserID, req.ToUserID, req.Amount); err != nil {
-		http.Error(w, "Failed to settle: "+err.Error(), http.StatusInternalServerError)
+	// Get group for split calculation
+	group, err := db.GetGroupByID(req.GroupID)
+	if err != nil {
+		http.Error(w, "Group not found", http.StatusBadRequest)
 		return
 	}
 
-	sendJSON(w, map[string]string{"status": "settled"})
-}
-
-// ============ ADMIN ENDPOINTS ============
-
-func (h *Handler) AdminLogin(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	expense, fieldErrs, err := prepareExpense(group, req, session.UserID, time.Now(), false)
+	if err != nil {
+		http.Error(w, "Failed to add expense: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	var req struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		sendJSON(w, AuthResponse{Success: false, Message: "Invalid request"})
+	if len(fieldErrs) > 0 {
+		sendValidationErrors(w, fieldErrs)
 		return
 	}
 
-	if req.Username != AdminUsername || req.Password != AdminPassword {
-		sendJSON(w, AuthResponse{Success: false, Message: "Invalid credentials"})
+	expenseID := auth.GenerateUserID()
+	if err := saveExpense(expenseID, req, expense); err != nil {
+		http.Error(w, "Failed to add expense: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Create admin session
-	token, _ := auth.CreateSession("admin", "Administrator")
-	setSessionCookie(w, token)
+	sendJSON(w, map[string]string{"status": "created", "expense_id": expenseID})
+}
 
-	sendJSON(w, AuthResponse{
-		Success:  true,
-		UserID:   "admin",
-		UserName: "Administrator",
-		IsAdmin:  true,
-	})
+// saveExpense stores a prepared expense and its balance updates in one
+// transaction
+func saveExpense(expenseID string, req AddExpenseRequest, expense *preparedExpense) error {
+	if expense.Receipt != nil {
+		return db.CreateItemizedExpense(expenseID, req.ExpenseDescription, expense.Category, expense.Date, expense.Amount, expense.Rate, req.GroupID, expense.Payers, expense.Splits, expense.Receipt)
+	}
+	return db.CreateExpense(expenseID, req.ExpenseDescription, expense.Category, expense.Date, expense.Amount, expense.Rate, req.GroupID, expense.Payers, expense.Splits)
 }
 
-func (h *Handler) isAdmin(r *http.Request) bool {
+// UpdateExpense handles PUT /api/expenses/{id}. The body has the same shape as
+// for adding an expense. The description, amount, currency, category and date
+// default to their current values when left out, and so do the payers when
+// neither paid_by_user_id nor payers is given. Leaving out split_type,
+// split_data, participants and items keeps the current splits and receipt,
+// which is only allowed while the amount and currency stay the same. The old
+// balance effect is reversed and the new one applied atomically.
+func (h *Handler) UpdateExpense(w http.ResponseWriter, r *http.Request) {
 	session := auth.GetUserFromRequest(r)
-	return session != nil && session.UserID == "admin"
-}
+	if session == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
 
-func (h *Handler) AdminGetUsers(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		http.Error(w, "Admin access required", http.StatusForbidden)
+	expenseID := strings.TrimPrefix(r.URL.Path, "/api/expenses/")
+	if expenseID == "" || strings.Contains(expenseID, "/") {
+		http.Error(w, "Expense ID required", http.StatusBadRequest)
 		return
 	}
 
-	users, err := db.GetAllUsers()
+	existing, err := db.GetExpenseByID(expenseID)
 	if err != nil {
-		http.Error(w, "Failed to get users", http.StatusInternalServerError)
+		http.Error(w, "Expense not found", http.StatusNotFound)
 		return
 	}
 
-	sendJSON(w, users)
-}
-
-func (h *Handler) AdminGetGroups(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		http.Error(w, "Admin access required", http.StatusForbidden)
+	// Verify user is in the expense's group
+	if !db.IsUserInGroup(session.UserID, existing.GroupID) {
+		http.Error(w, "Access denied", http.StatusForbidden)
 		return
 	}
-
-	groups, err := db.GetAllGroups()
-	if err != nil {
-		http.Error(w, "Failed to get groups", http.StatusInternalServerError)
+	if existing.DeletedAt != nil {
+		http.Error(w, "Expense is deleted; restore it first", http.StatusConflict)
 		return
 	}
 
-	sendJSON(w, groups)
-}
-
-func (h *Handler) AdminDeleteUser(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		http.Error(w, "Admin access required", http.StatusForbidden)
+	var req AddExpenseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	userID := r.URL.Query().Get("id")
-	if userID == "" {
-		http.Error(w, "User ID required", http.StatusBadRequest)
+	// An expense cannot move between groups
+	req.GroupID = existing.GroupID
+	if req.ExpenseDescription == "" {
+		req.ExpenseDescription = existing.ExpenseDescription
+	}
+	if req.ExpenseAmount == "" && len(req.Items) == 0 {
+		req.ExpenseAmount = json.Number(existing.ExpenseAmount.Decimal())
+	}
+	if req.Currency == "" {
+		req.Currency = string(existing.Currency)
+	}
+	if req.Category == "" {
+		req.Category = existing.Category
+	}
+	if req.ExpenseDate == "" {
+		req.ExpenseDate = existing.ExpenseDate
+	}
+	if req.PaidByUserID == "" && len(req.Payers) == 0 {
+		if len(existing.Payers) > 1 {
+			for _, payer := range existing.Payers {
+				req.Payers = append(req.Payers, PayerRequest{UserID: payer.UserID, Amount: json.Number(payer.Amount.Decimal())})
+			}
+		} else {
+			req.PaidByUserID = existing.PaidByUserID
+		}
+	}
+	keepSplits := req.SplitType == "" && req.SplitData == nil && req.Participants == nil && len(req.Items) == 0
+
+	group, err := db.GetGroupByID(existing.GroupID)
+	if err != nil {
+		http.Error(w, "Group not found", http.StatusBadRequest)
 		return
 	}
 
-	// Check if user has any pending balances (owes or is owed)
-	hasBalance, balanceMsg, err := db.UserHasPendingBalances(userID)
+	// A new currency or date is converted at the rate of the expense date
+	expense, fieldErrs, err := prepareExpense(group, req, session.UserID, time.Now(), keepSplits)
 	if err != nil {
-		http.Error(w, "Failed to check balances", http.StatusInternalServerError)
+		http.Error(w, "Failed to update expense: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	if hasBalance {
-		sendJSON(w, map[string]interface{}{
-			"success": false,
-			"message": balanceMsg,
-		})
+	if len(fieldErrs) > 0 {
+		sendValidationErrors(w, fieldErrs)
 		return
 	}
 
-	// Delete user
-	if err := db.DeleteUser(userID); err != nil {
-		http.Error(w, "Failed to delete user: "+err.Error(), http.StatusInternalServerError)
-		return
+	if keepSplits {
+		if expense.Amount != existing.ExpenseAmount {
+			sendValidationErrors(w, map[string]string{"split_type": "is required when the amount or currency changes"})
+			return
+		}
+		expense.Splits = existingSplits(group, existing.Splits)
+		expense.Receipt = existing.Receipt
 	}
 
-	sendJSON(w, map[string]interface{}{
-		"success": true,
-		"message": "User deleted",
-	})
-}
+	// Keep the rate the expense was recorded at unless its currency or date changes
+	if expense.Amount.Currency == existing.Currency && expense.Date.Format(dateLayout) == existing.ExpenseDate {
+		expense.Rate = existing.ExchangeRate
+	}
 
-func (h *Handler) AdminDeleteGroup(w http.ResponseWriter, r *http.Request) {
-	if !h.isAdmin(r) {
-		http.Error(w, "Admin access required", http.StatusForbidden)
+	if err := db.UpdateExpense(expenseID, req.ExpenseDescription, expense.Category, expense.Date, expense.Amount, expense.Rate, expense.Payers, expense.Splits, expense.Receipt); err != nil {
+		http.Error(w, "Failed to update expense: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	groupID := r.URL.Query().Get("id")
-	if groupID == "" {
-		http.Error(w, "Group ID required", http.StatusBadRequest)
+	sendJSON(w, map[string]string{"status": "updated", "expense_id": expenseID})
+}
+
+// existingSplits turns an expense's stored splits back into the shares to
+// record, keeping members who have since left the group
+func existingSplits(group *entity.Group, records []db.SplitRecord) []*entity.Split {
+	splits := make([]*entity.Split, 0, len(records))
+	for _, record := range records {
+		user := group.GetMember(record.UserID)
+		if user == nil {
+			user = &entity.User{UserID: record.UserID, UserName: record.UserName}
+		}
+		splits = append(splits, entity.NewSplit(user, record.Amount))
+	}
+	return splits
+}
+
+// DeleteExpense handles DELETE /api/expenses?expense_id=. Any member of the
+// expense's group may delete it; its balance effect is reversed atomically and
+// it can be brought back with RestoreExpense.
+func (h *Handler) DeleteExpense(w http.ResponseWriter, r *http.Request) {
+	session := auth.GetUserFromRequest(r)
+	if session == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	expenseID := r.URL.Query().Get("expense_id")
+	if expenseID == "" {
+		http.Error(w, "Expense ID required", http.StatusBadRequest)
+		return
+	}
+
+	expense, err := db.GetExpenseByID(expenseID)
+	if err != nil {
+		http.Error(w, "Expense not found", http.StatusNotFound)
+		return
+	}
+
+	// Verify user is in the expense's group
+	if !db.IsUserInGroup(session.UserID, expense.GroupID) {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return
+	}
+
+	if err := db.DeleteExpense(expenseID); err != nil {
+		if errors.Is(err, db.ErrExpenseDeleted) {
+			http.Error(w, "Expense is already deleted", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to delete expense: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sendJSON(w, map[string]string{"status": "deleted", "expense_id": expenseID})
+}
+
+// RestoreExpense handles POST /api/expenses/restore?expense_id=, bringing back
+// a deleted expense and re-applying its balance effect
+func (h *Handler) RestoreExpense(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session := auth.GetUserFromRequest(r)
+	if session == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	expenseID := r.URL.Query().Get("expense_id")
+	if expenseID == "" {
+		http.Error(w, "Expense ID required", http.StatusBadRequest)
+		return
+	}
+
+	expense, err := db.GetExpenseByID(expenseID)
+	if err != nil {
+		http.Error(w, "Expense not found", http.StatusNotFound)
+		return
+	}
+
+	// Verify user is in the expense's group
+	if !db.IsUserInGroup(session.UserID, expense.GroupID) {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return
+	}
+
+	if err := db.RestoreExpense(expenseID); err != nil {
+		if errors.Is(err, db.ErrExpenseNotDeleted) {
+			http.Error(w, "Expense is not deleted", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to restore expense: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sendJSON(w, map[string]string{"status": "restored", "expense_id": expenseID})
+}
+
+// GetDeletedExpenses handles GET /api/expenses/deleted?group_id=
+func (h *Handler) GetDeletedExpenses(w http.ResponseWriter, r *http.Request) {
+	session := auth.GetUserFromRequest(r)
+	if session == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	groupID := r.URL.Query().Get("group_id")
+	if groupID == "" {
+		http.Error(w, "Group ID required", http.StatusBadRequest)
+		return
+	}
+
+	// Verify user is in group
+	if !db.IsUserInGroup(session.UserID, groupID) {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return
+	}
+
+	expenses, err := db.GetDeletedGroupExpenses(groupID)
+	if err != nil {
+		sendJSON(w, []ExpenseResponse{})
+		return
+	}
+
+	sendJSON(w, expenses)
+}
+
+// preparedExpense is an expense request that passed validation, with its
+// payers and splits worked out
+type preparedExpense struct {
+	Amount   entity.Money
+	Category string
+	Date     time.Time
+	// Rate converts Amount's currency into the group's base currency
+	Rate    float64
+	Payers  []*entity.Payer
+	Splits  []*entity.Split
+	Receipt *db.ReceiptRecord
+}
+
+// prepareExpense validates an expense request against its group and runs the
+// split strategy, returning field errors for anything that is wrong and an
+// error when a lookup fails. With keepSplits the strategy is skipped and the
+// caller fills in stored splits.
+// The expense is dated today unless the request says otherwise, and amounts
+// in another currency are converted at the rate on the expense date.
+func prepareExpense(group *entity.Group, req AddExpenseRequest, sessionUserID string, today time.Time, keepSplits bool) (*preparedExpense, map[string]string, error) {
+	date := today
+	if req.ExpenseDate != "" {
+		parsed, err := time.Parse(dateLayout, req.ExpenseDate)
+		if err != nil {
+			return nil, map[string]string{"expense_date": "must be a date as YYYY-MM-DD"}, nil
+		}
+		// A day of slack lets clients ahead of the server's time zone use their own today
+		if parsed.Format(dateLayout) > today.AddDate(0, 0, 1).Format(dateLayout) {
+			return nil, map[string]string{"expense_date": "must not be in the future"}, nil
+		}
+		date = parsed
+	}
+
+	currency := group.BaseCurrency
+	if req.Currency != "" {
+		parsed, err := entity.ParseCurrency(req.Currency)
+		if err != nil {
+			return nil, map[string]string{"currency": "must be a three letter currency code"}, nil
+		}
+		currency = parsed
+	}
+	rate, err := db.GetExchangeRate(currency, group.BaseCurrency, date)
+	if errors.Is(err, db.ErrNoExchangeRate) {
+		return nil, map[string]string{"currency": "has no exchange rate to " + string(group.BaseCurrency) + " yet"}, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// An itemized expense may leave its total to be worked out from the receipt
+	var expenseAmount entity.Money
+	amountGiven := req.ExpenseAmount != "" || len(req.Items) == 0
+	if amountGiven {
+		parsed, err := entity.ParseMoney(req.ExpenseAmount.String(), currency)
+		if err != nil || !parsed.IsPositive() {
+			return nil, map[string]string{"expense_amount": "must be a positive amount"}, nil
+		}
+		expenseAmount = parsed
+	}
+
+	// Determine who paid (use request value or fall back to session user)
+	paidByUserID := req.PaidByUserID
+	if paidByUserID == "" {
+		paidByUserID = sessionUserID
+	}
+	if group.GetMember(paidByUserID) == nil {
+		return nil, map[string]string{"paid_by_user_id": "is not a member of this group"}, nil
+	}
+
+	// Stored splits being kept need no split strategy, which could reject them
+	// now that the group has changed
+	var splits []*entity.Split
+	var itemized *stragegy.ItemizedSplitStrategy
+	if !keepSplits {
+		var fieldErrs map[string]string
+		expenseAmount, splits, itemized, fieldErrs = prepareSplits(group, req, currency, expenseAmount, amountGiven)
+		if len(fieldErrs) > 0 {
+			return nil, fieldErrs, nil
+		}
+	}
+
+	payers, fieldErrs := buildPayers(group, req.Payers, paidByUserID, expenseAmount)
+	if len(fieldErrs) > 0 {
+		return nil, fieldErrs, nil
+	}
+
+	category, err := expenseCategory(group.GroupID, req)
+	if err != nil {
+		return nil, map[string]string{"category": "must be a built-in category or one of the group's own"}, nil
+	}
+
+	expense := &preparedExpense{
+		Amount:   expenseAmount,
+		Category: category,
+		Date:     date,
+		Rate:     rate,
+		Payers:   payers,
+		Splits:   splits,
+	}
+	if itemized != nil {
+		expense.Receipt = toReceiptRecord(itemized)
+	}
+	return expense, nil, nil
+}
+
+// prepareSplits runs the split strategy a request asks for, returning the
+// expense amount, which an itemized expense may leave to its receipt
+func prepareSplits(group *entity.Group, req AddExpenseRequest, currency entity.Currency, expenseAmount entity.Money, amountGiven bool) (entity.Money, []*entity.Split, *stragegy.ItemizedSplitStrategy, map[string]string) {
+	// Every participant must belong to the group
+	participants := make([]*entity.User, 0)
+	for _, participantID := range req.Participants {
+		member := group.GetMember(participantID)
+		if member == nil {
+			return expenseAmount, nil, nil, map[string]string{"participants": participantID + " is not a member of this group"}
+		}
+		participants = append(participants, member)
+	}
+
+	splitType, ok := parseSplitType(req.SplitType)
+	if !ok {
+		return expenseAmount, nil, nil, map[string]string{"split_type": splitTypeError}
+	}
+	if splitType == stragegy.Equal && len(req.SplitData) > 0 {
+		return expenseAmount, nil, nil, map[string]string{"split_data": "is not used by an equal split; list who takes part in participants"}
+	}
+	splitData := toSplitData(group, req.SplitData)
+
+	// An equal split only needs to know who takes part
+	if splitType == stragegy.Equal {
+		splitData = make(map[entity.User]float64)
+		for _, participant := range participants {
+			splitData[*participant] = 1
+		}
+	}
+
+	strategy := stragegy.GetSplitStrategy(splitType, group)
+	var itemized *stragegy.ItemizedSplitStrategy
+	if len(req.Items) > 0 {
+		var fieldErrs map[string]string
+		itemized, fieldErrs = buildItemizedSplitStrategy(group, req, currency)
+		if len(fieldErrs) > 0 {
+			return expenseAmount, nil, nil, fieldErrs
+		}
+		strategy = itemized
+		if !amountGiven {
+			expenseAmount = itemized.GetTotal()
+		}
+	}
+
+	// Calculate splits using strategy
+	splits, err := strategy.CalculateSplits(splitData, expenseAmount)
+	if err != nil {
+		var splitErrs stragegy.SplitErrors
+		if errors.As(err, &splitErrs) {
+			return expenseAmount, nil, nil, splitErrs.Fields()
+		}
+		return expenseAmount, nil, nil, map[string]string{"split_type": err.Error()}
+	}
+	return expenseAmount, splits, itemized, nil
+}
+
+// expenseCategory resolves the requested category, or suggests one from the
+// description when none was given. A failed suggestion is not an error; the
+// expense is filed under the default category instead.
+func expenseCategory(groupID string, req AddExpenseRequest) (string, error) {
+	if strings.TrimSpace(req.Category) != "" {
+		return db.ResolveCategory(groupID, strings.TrimSpace(req.Category))
+	}
+	category, err := db.SuggestCategory(groupID, req.ExpenseDescription)
+	if err != nil {
+		return db.DefaultCategory, nil
+	}
+	return category, nil
+}
+
+// buildPayers validates the payer contributions of a request, largest first.
+// Without any, paidByUserID is taken to have paid the whole amount.
+func buildPayers(group *entity.Group, payerReqs []PayerRequest, paidByUserID string, total entity.Money) ([]*entity.Payer, map[string]string) {
+	if len(payerReqs) == 0 {
+		return []*entity.Payer{entity.NewPayer(group.GetMember(paidByUserID), total)}, nil
+	}
+
+	fieldErrs := make(map[string]string)
+	payers := make([]*entity.Payer, 0)
+	seen := make(map[string]bool)
+	sum := entity.NewMoney(0, total.Currency)
+	for i, payerReq := range payerReqs {
+		field := fmt.Sprintf("payers[%d]", i)
+		member := group.GetMember(payerReq.UserID)
+		if member == nil {
+			fieldErrs[field+".user_id"] = "is not a member of this group"
+			continue
+		}
+		if seen[member.UserID] {
+			fieldErrs[field+".user_id"] = "is listed more than once"
+			continue
+		}
+		seen[member.UserID] = true
+		amount, err := entity.ParseMoney(payerReq.Amount.String(), total.Currency)
+		if err != nil || !amount.IsPositive() {
+			fieldErrs[field+".amount"] = "must be a positive amount"
+			continue
+		}
+		payers = append(payers, entity.NewPayer(member, amount))
+		sum = sum.Add(amount)
+	}
+	if len(fieldErrs) > 0 {
+		return nil, fieldErrs
+	}
+	if sum.Amount != total.Amount {
+		return nil, map[string]string{"payers": "payments add up to " + sum.Decimal() + " but the expense is " + total.Decimal()}
+	}
+
+	sort.SliceStable(payers, func(i, j int) bool {
+		return payers[i].Amount.Amount > payers[j].Amount.Amount
+	})
+	return payers, nil
+}
+
+// splitTypeError is the field error for a split type parseSplitType rejects
+const splitTypeError = "must be equal, percentage, exact, shares or adjustment"
+
+// parseSplitType converts a request split type to the enum, defaulting to
+// equal when none is given. It reports false for a type it does not know.
+func parseSplitType(splitType string) (stragegy.SplitType, bool) {
+	switch splitType {
+	case "", "equal":
+		return stragegy.Equal, true
+	case "percentage":
+		return stragegy.Percentage, true
+	case "exact":
+		return stragegy.Exact, true
+	case "shares":
+		return stragegy.Shares, true
+	case "adjustment":
+		return stragegy.Adjustment, true
+	default:
+		return stragegy.Equal, false
+	}
+}
+
+// toSplitData converts split data from userID keys to User keys. Unknown IDs
+// are kept as bare users so the strategy rejects them instead of silently
+// dropping them.
+func toSplitData(group *entity.Group, data map[string]float64) map[entity.User]float64 {
+	splitData := make(map[entity.User]float64)
+	for userID, value := range data {
+		if member := group.GetMember(userID); member != nil {
+			splitData[*member] = value
+		} else {
+			splitData[entity.User{UserID: userID}] = value
+		}
+	}
+	return splitData
+}
+
+// buildItemizedSplitStrategy parses the receipt part of a request, returning
+// field errors for amounts that cannot be read
+func buildItemizedSplitStrategy(group *entity.Group, req AddExpenseRequest, currency entity.Currency) (*stragegy.ItemizedSplitStrategy, map[string]string) {
+	fieldErrs := make(map[string]string)
+	parseOptional := func(field string, value json.Number) entity.Money {
+		if value == "" {
+			return entity.NewMoney(0, currency)
+		}
+		amount, err := entity.ParseMoney(value.String(), currency)
+		if err != nil {
+			fieldErrs[field] = "is not a valid amount"
+		}
+		return amount
+	}
+	tax := parseOptional("tax", req.Tax)
+	tip := parseOptional("tip", req.Tip)
+
+	items := make([]*stragegy.LineItem, 0)
+	for i, itemReq := range req.Items {
+		unitPrice, err := entity.ParseMoney(itemReq.UnitPrice.String(), currency)
+		if err != nil {
+			fieldErrs[fmt.Sprintf("items[%d].unit_price", i)] = "is not a valid amount"
+		}
+		quantity := itemReq.Quantity
+		if quantity == 0 {
+			quantity = 1
+		}
+
+		assigned := make([]*entity.User, 0)
+		for _, userID := range itemReq.AssignedUserIDs {
+			if member := group.GetMember(userID); member != nil {
+				assigned = append(assigned, member)
+			} else {
+				assigned = append(assigned, &entity.User{UserID: userID})
+			}
+		}
+
+		splitType, ok := parseSplitType(itemReq.SplitType)
+		if !ok {
+			fieldErrs[fmt.Sprintf("items[%d].split_type", i)] = splitTypeError
+		}
+		splitData := toSplitData(group, itemReq.SplitData)
+		if splitType == stragegy.Equal {
+			// Assigned users already say who shares the item
+			if len(itemReq.SplitData) > 0 {
+				fieldErrs[fmt.Sprintf("items[%d].split_data", i)] = "is not used by an equal split; list who shares the item in assigned_user_ids"
+			}
+			splitData = nil
+		}
+		items = append(items, stragegy.NewLineItem(itemReq.Name, unitPrice, quantity, assigned, splitType, splitData))
+	}
+
+	return stragegy.NewItemizedSplitStrategy(group, items, tax, tip), fieldErrs
+}
+
+// toReceiptRecord converts calculated line items into their stored form
+func toReceiptRecord(itemized *stragegy.ItemizedSplitStrategy) *db.ReceiptRecord {
+	receipt := &db.ReceiptRecord{
+		Items: make([]db.LineItemRecord, 0),
+		Tax:   itemized.Tax,
+		Tip:   itemized.Tip,
+	}
+	for _, item := range itemized.Items {
+		record := db.LineItemRecord{
+			Name:      item.Name,
+			UnitPrice: item.UnitPrice,
+			Quantity:  item.Quantity,
+			SplitType: strings.ToLower(item.SplitType.String()),
+			Splits:    make([]db.SplitRecord, 0),
+		}
+		for _, split := range item.Splits {
+			record.Splits = append(record.Splits, db.SplitRecord{
+				UserID:   split.User.UserID,
+				UserName: split.User.UserName,
+				Amount:   split.Amount,
+			})
+		}
+		receipt.Items = append(receipt.Items, record)
+	}
+	return receipt
+}
+
+// GetGroupExpenses handles GET /api/expenses?group_id=, optionally narrowed
+// to one category with &category= and to expense dates with &from= and &to=
+func (h *Handler) GetGroupExpenses(w http.ResponseWriter, r *http.Request) {
+	session := auth.GetUserFromRequest(r)
+	if session == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	groupID := r.URL.Query().Get("group_id")
+	if groupID == "" {
+		http.Error(w, "Group ID required", http.StatusBadRequest)
+		return
+	}
+
+	// Verify user is in group
+	if !db.IsUserInGroup(session.UserID, groupID) {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return
+	}
+
+	filter := db.ExpenseFilter{
+		Category: r.URL.Query().Get("category"),
+		From:     r.URL.Query().Get("from"),
+		To:       r.URL.Query().Get("to"),
+	}
+	fieldErrs := make(map[string]string)
+	for field, value := range map[string]string{"from": filter.From, "to": filter.To} {
+		if _, err := time.Parse(dateLayout, value); value != "" && err != nil {
+			fieldErrs[field] = "must be a date as YYYY-MM-DD"
+		}
+	}
+	if len(fieldErrs) > 0 {
+		sendValidationErrors(w, fieldErrs)
+		return
+	}
+
+	expenses, err := db.GetGroupExpenses(groupID, filter)
+	if err != nil {
+		sendJSON(w, []ExpenseResponse{})
+		return
+	}
+
+	sendJSON(w, expenses)
+}
+
+// ============ BALANCE/SETTLE ENDPOINTS ============
+
+func (h *Handler) GetMyBalanceSummary(w http.ResponseWriter, r *http.Request) {
+	session := auth.GetUserFromRequest(r)
+	if session == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	summary, err := db.GetUserBalanceSummary(session.UserID)
+	if err != nil {
+		http.Error(w, "Failed to get balance summary", http.StatusInternalServerError)
+		return
+	}
+
+	sendJSON(w, summary)
+}
+
+func (h *Handler) GetGroupBalances(w http.ResponseWriter, r *http.Request) {
+	session := auth.GetUserFromRequest(r)
+	if session == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	groupID := r.URL.Query().Get("group_id")
+	if groupID == "" {
+		http.Error(w, "Group ID required", http.StatusBadRequest)
+		return
+	}
+
+	// Verify user is in group
+	if !db.IsUserInGroup(session.UserID, groupID) {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return
+	}
+
+	balances, err := db.GetGroupBalances(groupID)
+	if err != nil {
+		sendJSON(w, []BalanceResponse{})
+		return
+	}
+
+	response := make([]BalanceResponse, 0)
+	for _, b := range balances {
+		response = append(response, BalanceResponse{
+			GroupID:      b.GroupID,
+			FromUserID:   b.FromUserID,
+			FromUserName: b.FromUserName,
+			ToUserID:     b.ToUserID,
+			ToUserName:   b.ToUserName,
+			Amount:       b.Amount,
+			Currency:     b.Currency,
+		})
+	}
+
+	sendJSON(w, response)
+}
+
+// GetSimplifiedBalances returns the group's debts reduced to as few payments
+// as possible, leaving every member's net position unchanged
+func (h *Handler) GetSimplifiedBalances(w http.ResponseWriter, r *http.Request) {
+	session := auth.GetUserFromRequest(r)
+	if session == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	groupID := r.URL.Query().Get("group_id")
+	if groupID == "" {
+		http.Error(w, "Group ID required", http.StatusBadRequest)
+		return
+	}
+
+	// Verify user is in group
+	if !db.IsUserInGroup(session.UserID, groupID) {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return
+	}
+
+	balances, err := db.GetGroupBalances(groupID)
+	if err != nil {
+		sendJSON(w, []BalanceResponse{})
+		return
+	}
+
+	debts := make([]*balancesheet.Debt, 0, len(balances))
+	for _, b := range balances {
+		debts = append(debts, &balancesheet.Debt{
+			From:   &entity.User{UserID: b.FromUserID, UserName: b.FromUserName},
+			To:     &entity.User{UserID: b.ToUserID, UserName: b.ToUserName},
+			Amount: b.Amount,
+		})
+	}
+
+	response := make([]BalanceResponse, 0)
+	for _, debt := range balancesheet.SimplifyDebts(debts) {
+		response = append(response, BalanceResponse{
+			GroupID:      groupID,
+			FromUserID:   debt.From.UserID,
+			FromUserName: debt.From.UserName,
+			ToUserID:     debt.To.UserID,
+			ToUserName:   debt.To.UserName,
+			Amount:       debt.Amount,
+			Currency:     debt.Amount.Currency,
+		})
+	}
+
+	sendJSON(w, response)
+}
+
+func (h *Handler) Settle(w http.ResponseWriter, r *http.Request) {
+	session := auth.GetUserFromRequest(r)
+	if session == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req SettleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Verify user is in group
+	if !db.IsUserInGroup(session.UserID, req.GroupID) {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return
+	}
+
+	// Settlements are paid in the group's base currency
+	group, err := db.GetGroupByID(req.GroupID)
+	if err != nil {
+		http.Error(w, "Group not found", http.StatusBadRequest)
+		return
+	}
+
+	fieldErrs := validateSettlementDetails(req.Note, req.Method)
+	mode, ok := db.ParseSettleMode(req.Mode)
+	if !ok {
+		fieldErrs["mode"] = "must be one of partial, exact or overpayment"
+	}
+	if req.ToUserID == session.UserID {
+		fieldErrs["to_user_id"] = "cannot be yourself"
+	} else if !db.IsUserInGroup(req.ToUserID, req.GroupID) {
+		fieldErrs["to_user_id"] = "is not a member of this group"
+	}
+
+	// Exact payoffs may leave the amount out to pay the whole debt
+	amount := entity.NewMoney(0, group.BaseCurrency)
+	if req.Amount != "" || mode != db.SettleExact {
+		parsed, err := entity.ParseMoney(req.Amount.String(), group.BaseCurrency)
+		if err != nil || !parsed.IsPositive() {
+			fieldErrs["amount"] = "must be a positive amount"
+		}
+		amount = parsed
+	}
+	if len(fieldErrs) > 0 {
+		sendValidationErrors(w, fieldErrs)
+		return
+	}
+
+	settlementID := auth.GenerateUserID()
+	settled, err := db.CreateSettlement(settlementID, req.GroupID, session.UserID, req.ToUserID, amount, strings.TrimSpace(req.Note), strings.TrimSpace(req.Method), mode)
+	if err != nil {
+		var settleErr *db.SettlementError
+		if errors.As(err, &settleErr) {
+			sendValidationErrors(w, map[string]string{settleErr.Field: settleErr.Message})
+			return
+		}
+		http.Error(w, "Failed to settle: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sendJSON(w, map[string]interface{}{
+		"status":        db.SettlementPending,
+		"settlement_id": settlementID,
+		"amount":        settled,
+		"currency":      settled.Currency,
+	})
+}
+
+// GetSettlements lists a group's settlements when group_id is given, and the
+// session user's settlements across all groups otherwise
+func (h *Handler) GetSettlements(w http.ResponseWriter, r *http.Request) {
+	session := auth.GetUserFromRequest(r)
+	if session == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var settlements []db.SettlementRecord
+	var err error
+	if groupID := r.URL.Query().Get("group_id"); groupID != "" {
+		// Verify user is in group
+		if !db.IsUserInGroup(session.UserID, groupID) {
+			http.Error(w, "Access denied", http.StatusForbidden)
+			return
+		}
+		settlements, err = db.GetGroupSettlements(groupID)
+	} else {
+		settlements, err = db.GetUserSettlements(session.UserID)
+	}
+	if err != nil {
+		sendJSON(w, []db.SettlementRecord{})
+		return
+	}
+
+	sendJSON(w, settlements)
+}
+
+// GetPendingSettlements lists settlements other members recorded with the
+// session user that are waiting for them to confirm or dispute
+func (h *Handler) GetPendingSettlements(w http.ResponseWriter, r *http.Request) {
+	session := auth.GetUserFromRequest(r)
+	if session == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	settlements, err := db.GetPendingSettlements(session.UserID)
+	if err != nil {
+		sendJSON(w, []db.SettlementRecord{})
+		return
+	}
+
+	sendJSON(w, settlements)
+}
+
+// ConfirmSettlement handles POST /api/settlements/confirm?settlement_id=. Only
+// once confirmed does a settlement change balances.
+func (h *Handler) ConfirmSettlement(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session := auth.GetUserFromRequest(r)
+	if session == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	settlementID := r.URL.Query().Get("settlement_id")
+	if settlementID == "" {
+		http.Error(w, "Settlement ID required", http.StatusBadRequest)
+		return
+	}
+
+	if err := db.ConfirmSettlement(settlementID, session.UserID); err != nil {
+		var settleErr *db.SettlementError
+		if errors.As(err, &settleErr) {
+			sendValidationErrors(w, map[string]string{settleErr.Field: settleErr.Message})
+			return
+		}
+		sendSettlementStatusError(w, err, "Failed to confirm settlement: ")
+		return
+	}
+
+	sendJSON(w, map[string]string{"status": db.SettlementConfirmed, "settlement_id": settlementID})
+}
+
+// DisputeSettlement handles POST /api/settlements/dispute?settlement_id=,
+// rejecting a pending settlement with an optional reason
+func (h *Handler) DisputeSettlement(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session := auth.GetUserFromRequest(r)
+	if session == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	settlementID := r.URL.Query().Get("settlement_id")
+	if settlementID == "" {
+		http.Error(w, "Settlement ID required", http.StatusBadRequest)
+		return
+	}
+
+	var req DisputeSettlementRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if len(reason) > 280 {
+		sendValidationErrors(w, map[string]string{"reason": "must be at most 280 characters"})
+		return
+	}
+
+	if err := db.DisputeSettlement(settlementID, session.UserID, reason); err != nil {
+		sendSettlementStatusError(w, err, "Failed to dispute settlement: ")
+		return
+	}
+
+	sendJSON(w, map[string]string{"status": db.SettlementDisputed, "settlement_id": settlementID})
+}
+
+// sendSettlementStatusError maps errors from confirming or disputing a
+// settlement to a response
+func sendSettlementStatusError(w http.ResponseWriter, err error, prefix string) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Settlement not found", http.StatusNotFound)
+	case errors.Is(err, db.ErrNotSettlementPayee):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, db.ErrSettlementNotPending):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, prefix+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// validateSettlementDetails checks the optional note and payment method
+func validateSettlementDetails(note, method string) map[string]string {
+	fieldErrs := make(map[string]string)
+	if len(strings.TrimSpace(note)) > 280 {
+		fieldErrs["note"] = "must be at most 280 characters"
+	}
+	if len(strings.TrimSpace(method)) > 40 {
+		fieldErrs["method"] = "must be at most 40 characters"
+	}
+	return fieldErrs
+}
+
+// GetMyNetBalances returns the session user's balance with each counterparty
+// netted across all shared groups
+func (h *Handler) GetMyNetBalances(w http.ResponseWriter, r *http.Request) {
+	session := auth.GetUserFromRequest(r)
+	if session == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	balances, err := db.GetUserNetBalances(session.UserID)
+	if err != nil {
+		sendJSON(w, []db.CounterpartyBalance{})
+		return
+	}
+
+	sendJSON(w, balances)
+}
+
+// SettleNet pays a counterparty against the net balance across all shared
+// groups, spreading the payment over the underlying group debts
+func (h *Handler) SettleNet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session := auth.GetUserFromRequest(r)
+	if session == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req NetSettleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.ToUserID == "" || req.ToUserID == session.UserID {
+		http.Error(w, "A different user to pay is required", http.StatusBadRequest)
+		return
+	}
+
+	// Balances only net within a currency; without one, pay the first currency
+	// the session user owes the counterparty in
+	var currency entity.Currency
+	if req.Currency != "" {
+		parsed, err := entity.ParseCurrency(req.Currency)
+		if err != nil {
+			sendValidationErrors(w, map[string]string{"currency": "must be a three letter currency code"})
+			return
+		}
+		currency = parsed
+	} else {
+		balances, err := db.GetUserNetBalances(session.UserID)
+		if err != nil {
+			http.Error(w, "Failed to load balances", http.StatusInternalServerError)
+			return
+		}
+		currency = entity.DefaultCurrency
+		for _, b := range balances {
+			if b.UserID == req.ToUserID && b.NetAmount.IsPositive() {
+				currency = b.Currency
+				break
+			}
+		}
+	}
+
+	// Without an amount, the whole net balance is paid
+	amount := entity.NewMoney(0, currency)
+	if req.Amount != "" {
+		parsed, err := entity.ParseMoney(req.Amount.String(), currency)
+		if err != nil || !parsed.IsPositive() {
+			sendValidationErrors(w, map[string]string{"amount": "must be a positive amount"})
+			return
+		}
+		amount = parsed
+	}
+
+	if fieldErrs := validateSettlementDetails(req.Note, req.Method); len(fieldErrs) > 0 {
+		sendValidationErrors(w, fieldErrs)
+		return
+	}
+
+	amount, settlements, err := db.SettleAcrossGroups(auth.GenerateUserID(), session.UserID, req.ToUserID, amount, strings.TrimSpace(req.Note), strings.TrimSpace(req.Method))
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNothingOwed), errors.Is(err, db.ErrNetSettlementPending), errors.Is(err, db.ErrAmountExceedsNet):
+			sendValidationErrors(w, map[string]string{"amount": err.Error()})
+		default:
+			http.Error(w, "Failed to settle: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	sendJSON(w, map[string]interface{}{
+		"status":      db.SettlementPending,
+		"amount":      amount,
+		"currency":    amount.Currency,
+		"settlements": settlements,
+	})
+}
+
+// ============ ADMIN ENDPOINTS ============
+
+func (h *Handler) AdminLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendJSON(w, AuthResponse{Success: false, Message: "Invalid request"})
+		return
+	}
+
+	if req.Username != AdminUsername || req.Password != AdminPassword {
+		sendJSON(w, AuthResponse{Success: false, Message: "Invalid credentials"})
+		return
+	}
+
+	// Create admin session
+	token, _ := auth.CreateSession("admin", "Administrator")
+	setSessionCookie(w, token)
+
+	sendJSON(w, AuthResponse{
+		Success:  true,
+		UserID:   "admin",
+		UserName: "Administrator",
+		IsAdmin:  true,
+	})
+}
+
+func (h *Handler) isAdmin(r *http.Request) bool {
+	session := auth.GetUserFromRequest(r)
+	return session != nil && session.UserID == "admin"
+}
+
+func (h *Handler) AdminGetUsers(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		http.Error(w, "Admin access required", http.StatusForbidden)
+		return
+	}
+
+	users, err := db.GetAllUsers()
+	if err != nil {
+		http.Error(w, "Failed to get users", http.StatusInternalServerError)
+		return
+	}
+
+	sendJSON(w, users)
+}
+
+func (h *Handler) AdminGetGroups(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		http.Error(w, "Admin access required", http.StatusForbidden)
+		return
+	}
+
+	groups, err := db.GetAllGroups()
+	if err != nil {
+		http.Error(w, "Failed to get groups", http.StatusInternalServerError)
+		return
+	}
+
+	sendJSON(w, groups)
+}
+
+func (h *Handler) AdminDeleteUser(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		http.Error(w, "Admin access required", http.StatusForbidden)
+		return
+	}
+
+	userID := r.URL.Query().Get("id")
+	if userID == "" {
+		http.Error(w, "User ID required", http.StatusBadRequest)
+		return
+	}
+
+	// Check if user has any pending balances (owes or is owed)
+	hasBalance, balanceMsg, err := db.UserHasPendingBalances(userID)
+	if err != nil {
+		http.Error(w, "Failed to check balances", http.StatusInternalServerError)
+		return
+	}
+	if hasBalance {
+		sendJSON(w, map[string]interface{}{
+			"success": false,
+			"message": balanceMsg,
+		})
+		return
+	}
+
+	// Delete user
+	if err := db.DeleteUser(userID); err != nil {
+		http.Error(w, "Failed to delete user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sendJSON(w, map[string]interface{}{
+		"success": true,
+		"message": "User deleted",
+	})
+}
+
+func (h *Handler) AdminDeleteGroup(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		http.Error(w, "Admin access required", http.StatusForbidden)
+		return
+	}
+
+	groupID := r.URL.Query().Get("id")
+	if groupID == "" {
+		http.Error(w, "Group ID required", http.StatusBadRequest)
 		return
 	}
 
@@ -711,11 +1922,21 @@ func (h *Handler) AdminDeleteGroup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Attachment files are removed once their records are gone
+	attachments, err := db.GetGroupAttachments(groupID)
+	if err != nil {
+		http.Error(w, "Failed to delete group: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	// Delete group
 	if err := db.DeleteGroup(groupID); err != nil {
 		http.Error(w, "Failed to delete group: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	for i := range attachments {
+		h.deleteAttachmentBlobs(&attachments[i])
+	}
 
 	sendJSON(w, map[string]interface{}{
 		"success": true,
@@ -723,6 +1944,178 @@ func (h *Handler) AdminDeleteGroup(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// AdminBalanceDrift reports pairs whose stored balance disagrees with the
+// ledger on GET, and rebuilds the balances table from the ledger on POST
+func (h *Handler) AdminBalanceDrift(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		http.Error(w, "Admin access required", http.StatusForbidden)
+		return
+	}
+
+	drift, err := db.CheckBalanceDrift()
+	if err != nil {
+		http.Error(w, "Failed to check balances: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		sendJSON(w, map[string]interface{}{
+			"in_sync": len(drift) == 0,
+			"drift":   drift,
+		})
+	case http.MethodPost:
+		if err := db.RebuildBalances(); err != nil {
+			http.Error(w, "Failed to rebuild balances: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sendJSON(w, map[string]interface{}{
+			"success":  true,
+			"message":  fmt.Sprintf("Balances rebuilt from ledger, %d drifted rows repaired", len(drift)),
+			"repaired": drift,
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// AdminExchangeRates lists the latest rate of every pair on GET, or the
+// history of one pair given ?from=&to=, and stores a dated rate on POST.
+// Expenses pick up the rate of the day they were made.
+func (h *Handler) AdminExchangeRates(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdmin(r) {
+		http.Error(w, "Admin access required", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		var rates []db.ExchangeRate
+		var err error
+		from, to := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+		if from != "" && to != "" {
+			rates, err = db.GetExchangeRateHistory(entity.Currency(strings.ToUpper(from)), entity.Currency(strings.ToUpper(to)))
+		} else {
+			rates, err = db.GetExchangeRates()
+		}
+		if err != nil {
+			sendJSON(w, []db.ExchangeRate{})
+			return
+		}
+		sendJSON(w, rates)
+	case http.MethodPost:
+		var req ExchangeRateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		fieldErrs := make(map[string]string)
+		from, err := entity.ParseCurrency(req.FromCurrency)
+		if err != nil {
+			fieldErrs["from_currency"] = "must be a three letter currency code"
+		}
+		to, err := entity.ParseCurrency(req.ToCurrency)
+		if err != nil {
+			fieldErrs["to_currency"] = "must be a three letter currency code"
+		} else if to == from {
+			fieldErrs["to_currency"] = "must differ from from_currency"
+		}
+		rate, err := req.Rate.Float64()
+		if err != nil || rate <= 0 {
+			fieldErrs["rate"] = "must be a positive number"
+		}
+		date := time.Now()
+		if req.Date != "" {
+			date, err = time.Parse(dateLayout, req.Date)
+			if err != nil {
+				fieldErrs["date"] = "must be a date like 2024-01-31"
+			}
+		}
+		if len(fieldErrs) > 0 {
+			sendValidationErrors(w, fieldErrs)
+			return
+		}
+
+		if err := db.SetExchangeRate(from, to, date, rate); err != nil {
+			http.Error(w, "Failed to save exchange rate: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sendJSON(w, map[string]interface{}{
+			"success":       true,
+			"from_currency": from,
+			"to_currency":   to,
+			"rate_date":     date.Format(dateLayout),
+			"rate":          rate,
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// maxRateFileSize bounds uploaded rate files; the ECB's full history is a
+// few megabytes
+const maxRateFileSize = 32 << 20
+
+// AdminImportExchangeRates handles POST /api/admin/exchange-rates/import with
+// an ECB reference rate file, CSV or XML, either as the raw body or as the
+// "file" field of a multipart form
+func (h *Handler) AdminImportExchangeRates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.isAdmin(r) {
+		http.Error(w, "Admin access required", http.StatusForbidden)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRateFileSize)
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "Rate file required", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, "Failed to read rate file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rates, err := db.ParseECBRates(data)
+	if err != nil {
+		sendValidationErrors(w, map[string]string{"file": err.Error()})
+		return
+	}
+	imported, err := db.ImportExchangeRates(rates)
+	if err != nil {
+		http.Error(w, "Failed to import exchange rates: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	first, last := rates[0].RateDate, rates[0].RateDate
+	for _, rate := range rates {
+		if rate.RateDate < first {
+			first = rate.RateDate
+		}
+		if rate.RateDate > last {
+			last = rate.RateDate
+		}
+	}
+	sendJSON(w, map[string]interface{}{
+		"success":   true,
+		"message":   fmt.Sprintf("Imported %d rates from %s to %s", imported, first, last),
+		"imported":  imported,
+		"from_date": first,
+		"to_date":   last,
+	})
+}
+
 // ============ HELPERS ============
 
 func sendJSON(w http.ResponseWriter, data interface{}) {
@@ -730,6 +2123,15 @@ func sendJSON(w http.ResponseWriter, data interface{}) {
 	json.NewEncoder(w).Encode(data)
 }
 
+func sendValidationErrors(w http.ResponseWriter, fields map[string]string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(ValidationErrorResponse{
+		Message: "Validation failed",
+		Errors:  fields,
+	})
+}
+
 func setSessionCookie(w http.ResponseWriter, token string) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     "session_token",
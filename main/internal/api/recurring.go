@@ -0,0 +1,427 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"splitwise/main/internal/auth"
+	"splitwise/main/internal/db"
+	"strings"
+	"time"
+)
+
+const (
+	// recurringCheckInterval is how often the scheduler looks for due occurrences
+	recurringCheckInterval = 15 * time.Minute
+	// maxRecurringBackdate is how far in the past a schedule may start
+	maxRecurringBackdate = 366 * 24 * time.Hour
+	// recurringCatchUpLimit caps the past occurrences created while a request
+	// waits; the scheduler creates the rest on its next run
+	recurringCatchUpLimit = 31
+)
+
+// RecurringExpenseRequest is an expense request plus the schedule to repeat
+// it on. The schedule runs until EndDate or Count occurrences, whichever is
+// first, or indefinitely when neither is set.
+type RecurringExpenseRequest struct {
+	AddExpenseRequest
+	Frequency string `json:"frequency"`
+	// Interval repeats every Interval days, weeks, months or years (default 1)
+	Interval int `json:"interval"`
+	// StartDate is the first occurrence, as YYYY-MM-DD (default: today)
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+	Count     int    `json:"count"`
+}
+
+// CreateRecurringExpense handles POST /api/recurring. The expense is
+// validated as if it were added now, and up to recurringCatchUpLimit
+// occurrences already due are created before responding.
+func (h *Handler) CreateRecurringExpense(w http.ResponseWriter, r *http.Request) {
+	session := auth.GetUserFromRequest(r)
+	if session == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req RecurringExpenseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !db.IsUserInGroup(session.UserID, req.GroupID) {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return
+	}
+
+	group, err := db.GetGroupByID(req.GroupID)
+	if err != nil {
+		http.Error(w, "Group not found", http.StatusBadRequest)
+		return
+	}
+
+	template, fieldErrs := recurringSchedule(req, time.Now())
+	if len(fieldErrs) > 0 {
+		sendValidationErrors(w, fieldErrs)
+		return
+	}
+
+	// Each occurrence is dated the day it falls on
+	req.ExpenseDate = ""
+
+	// Occurrences are created without a session, so the payer is fixed now
+	if req.PaidByUserID == "" && len(req.Payers) == 0 {
+		req.PaidByUserID = session.UserID
+	}
+	_, fieldErrs, err = prepareExpense(group, req.AddExpenseRequest, session.UserID, time.Now(), false)
+	if err != nil {
+		http.Error(w, "Failed to create recurring expense: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(fieldErrs) > 0 {
+		sendValidationErrors(w, fieldErrs)
+		return
+	}
+
+	expense, err := json.Marshal(req.AddExpenseRequest)
+	if err != nil {
+		http.Error(w, "Failed to create recurring expense: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	template.TemplateID = auth.GenerateUserID()
+	template.GroupID = req.GroupID
+	template.CreatedBy = session.UserID
+	template.Expense = expense
+	template.Description = req.ExpenseDescription
+
+	if err := db.CreateRecurringExpense(template); err != nil {
+		http.Error(w, "Failed to create recurring expense: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.materializeRecurring(template, time.Now().Format(dateLayout), recurringCatchUpLimit)
+
+	if stored, err := db.GetRecurringExpense(template.TemplateID); err == nil {
+		template = stored
+	}
+	sendJSON(w, template)
+}
+
+// recurringSchedule validates the schedule part of a request
+func recurringSchedule(req RecurringExpenseRequest, now time.Time) (*db.RecurringExpense, map[string]string) {
+	fieldErrs := make(map[string]string)
+
+	if !db.ValidFrequency(req.Frequency) {
+		fieldErrs["frequency"] = "must be daily, weekly, monthly or yearly"
+	}
+
+	interval := req.Interval
+	if interval == 0 {
+		interval = 1
+	}
+	if interval < 1 || interval > 365 {
+		fieldErrs["interval"] = "must be between 1 and 365"
+	}
+
+	startDate := req.StartDate
+	if startDate == "" {
+		startDate = now.Format(dateLayout)
+	} else if parsed, err := time.Parse(dateLayout, startDate); err != nil {
+		fieldErrs["start_date"] = "must be a date as YYYY-MM-DD"
+	} else if parsed.Format(dateLayout) < now.Add(-maxRecurringBackdate).Format(dateLayout) {
+		fieldErrs["start_date"] = "must be within the past year"
+	}
+
+	if req.EndDate != "" {
+		if _, err := time.Parse(dateLayout, req.EndDate); err != nil {
+			fieldErrs["end_date"] = "must be a date as YYYY-MM-DD"
+		} else if req.EndDate < startDate {
+			fieldErrs["end_date"] = "must not be before start_date"
+		}
+	}
+
+	if req.Count < 0 {
+		fieldErrs["count"] = "must not be negative"
+	}
+
+	if len(fieldErrs) > 0 {
+		return nil, fieldErrs
+	}
+	return &db.RecurringExpense{
+		Frequency:      req.Frequency,
+		Interval:       interval,
+		StartDate:      startDate,
+		EndDate:        req.EndDate,
+		MaxOccurrences: req.Count,
+	}, nil
+}
+
+// GetRecurringExpenses handles GET /api/recurring?group_id=
+func (h *Handler) GetRecurringExpenses(w http.ResponseWriter, r *http.Request) {
+	session := auth.GetUserFromRequest(r)
+	if session == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	groupID := r.URL.Query().Get("group_id")
+	if groupID == "" {
+		http.Error(w, "Group ID required", http.StatusBadRequest)
+		return
+	}
+
+	if !db.IsUserInGroup(session.UserID, groupID) {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return
+	}
+
+	templates, err := db.GetGroupRecurringExpenses(groupID)
+	if err != nil {
+		http.Error(w, "Failed to get recurring expenses", http.StatusInternalServerError)
+		return
+	}
+
+	sendJSON(w, templates)
+}
+
+// UpdateRecurringExpense handles PUT /api/recurring?template_id=. The body
+// replaces the expense each occurrence is created from; an empty body keeps
+// the stored one. Either way it is validated against the group as it is now
+// and a paused template resumes, catching up on the occurrences it missed.
+func (h *Handler) UpdateRecurringExpense(w http.ResponseWriter, r *http.Request) {
+	session := auth.GetUserFromRequest(r)
+	if session == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	templateID := r.URL.Query().Get("template_id")
+	if templateID == "" {
+		http.Error(w, "Template ID required", http.StatusBadRequest)
+		return
+	}
+
+	template, err := db.GetRecurringExpense(templateID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Recurring expense not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get recurring expense", http.StatusInternalServerError)
+		return
+	}
+
+	if !db.IsUserInGroup(session.UserID, template.GroupID) {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return
+	}
+
+	var req AddExpenseRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err == io.EOF {
+		err = json.Unmarshal(template.Expense, &req)
+	}
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	group, err := db.GetGroupByID(template.GroupID)
+	if err != nil {
+		http.Error(w, "Group not found", http.StatusBadRequest)
+		return
+	}
+
+	// The template stays in its group, and occurrences keep their own dates
+	req.GroupID = template.GroupID
+	req.ExpenseDate = ""
+	if req.PaidByUserID == "" && len(req.Payers) == 0 {
+		req.PaidByUserID = session.UserID
+	}
+	_, fieldErrs, err := prepareExpense(group, req, session.UserID, time.Now(), false)
+	if err != nil {
+		http.Error(w, "Failed to update recurring expense: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(fieldErrs) > 0 {
+		sendValidationErrors(w, fieldErrs)
+		return
+	}
+
+	expense, err := json.Marshal(req)
+	if err != nil {
+		http.Error(w, "Failed to update recurring expense: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := db.ResumeRecurringExpense(templateID, expense, req.ExpenseDescription); err != nil {
+		http.Error(w, "Failed to update recurring expense: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	template.Expense = expense
+	template.Description = req.ExpenseDescription
+	template.Paused = false
+	template.LastError = ""
+	h.materializeRecurring(template, time.Now().Format(dateLayout), recurringCatchUpLimit)
+
+	if stored, err := db.GetRecurringExpense(templateID); err == nil {
+		template = stored
+	}
+	sendJSON(w, template)
+}
+
+// DeleteRecurringExpense handles DELETE /api/recurring?template_id=. It stops
+// the schedule; expenses already created are kept.
+func (h *Handler) DeleteRecurringExpense(w http.ResponseWriter, r *http.Request) {
+	session := auth.GetUserFromRequest(r)
+	if session == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	templateID := r.URL.Query().Get("template_id")
+	if templateID == "" {
+		http.Error(w, "Template ID required", http.StatusBadRequest)
+		return
+	}
+
+	template, err := db.GetRecurringExpense(templateID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Recurring expense not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to get recurring expense", http.StatusInternalServerError)
+		return
+	}
+
+	if !db.IsUserInGroup(session.UserID, template.GroupID) {
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return
+	}
+
+	if err := db.DeleteRecurringExpense(templateID); err != nil {
+		http.Error(w, "Failed to delete recurring expense: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sendJSON(w, map[string]string{"status": "deleted", "template_id": templateID})
+}
+
+// StartRecurringScheduler creates due recurring expenses now and then every
+// recurringCheckInterval, for as long as the process runs. Occurrences missed
+// while the server was down are caught up on the first run.
+func (h *Handler) StartRecurringScheduler() {
+	go func() {
+		h.RunDueRecurringExpenses(time.Now())
+		ticker := time.NewTicker(recurringCheckInterval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			h.RunDueRecurringExpenses(now)
+		}
+	}()
+}
+
+// RunDueRecurringExpenses creates every occurrence due on or before now
+func (h *Handler) RunDueRecurringExpenses(now time.Time) {
+	today := now.Format(dateLayout)
+	templates, err := db.GetDueRecurringExpenses(today)
+	if err != nil {
+		log.Printf("recurring expenses: %v", err)
+		return
+	}
+	for i := range templates {
+		h.materializeRecurring(&templates[i], today, 0)
+	}
+}
+
+// materializeRecurring creates a template's occurrences up to today, at most
+// limit of them when limit is positive. Each occurrence gets an expense ID
+// derived from the template and date, so one that was created before a
+// crash, but not yet recorded on the template, is skipped rather than added
+// twice.
+func (h *Handler) materializeRecurring(template *db.RecurringExpense, today string, limit int) {
+	for created := 0; template.NextDate != "" && template.NextDate <= today; created++ {
+		if limit > 0 && created == limit {
+			return
+		}
+		expenseID := template.TemplateID + "-" + strings.ReplaceAll(template.NextDate, "-", "")
+		exists, err := db.ExpenseExists(expenseID)
+		if err != nil {
+			log.Printf("recurring expense %s: %v", template.TemplateID, err)
+			return
+		}
+
+		if !exists {
+			fieldErrs, err := createOccurrence(template, expenseID)
+			if len(fieldErrs) > 0 {
+				// The group changed so the expense is no longer valid, for
+				// example its payer left; retrying would fail the same way
+				reason := describeFieldErrors(fieldErrs)
+				log.Printf("recurring expense %s paused: %s", template.TemplateID, reason)
+				if err := db.PauseRecurringExpense(template.TemplateID, reason); err != nil {
+					log.Printf("recurring expense %s: %v", template.TemplateID, err)
+				}
+				return
+			}
+			if err != nil {
+				log.Printf("recurring expense %s: %v", template.TemplateID, err)
+				return
+			}
+		}
+
+		next := template.NextOccurrence(template.Occurrences + 1)
+		if err := db.AdvanceRecurringExpense(template.TemplateID, template.Occurrences+1, next); err != nil {
+			log.Printf("recurring expense %s: %v", template.TemplateID, err)
+			return
+		}
+		template.Occurrences++
+		template.NextDate = next
+	}
+}
+
+// createOccurrence adds one occurrence of a template the same way AddExpense
+// adds an expense, dated and converted on the occurrence date
+func createOccurrence(template *db.RecurringExpense, expenseID string) (map[string]string, error) {
+	var req AddExpenseRequest
+	if err := json.Unmarshal(template.Expense, &req); err != nil {
+		return nil, err
+	}
+
+	group, err := db.GetGroupByID(template.GroupID)
+	if err == sql.ErrNoRows {
+		return map[string]string{"group_id": "group no longer exists"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	req.ExpenseDate = template.NextDate
+	expense, fieldErrs, err := prepareExpense(group, req, template.CreatedBy, time.Now(), false)
+	if err != nil {
+		return nil, err
+	}
+	if len(fieldErrs) > 0 {
+		return fieldErrs, nil
+	}
+	return nil, saveExpense(expenseID, req, expense)
+}
+
+func describeFieldErrors(fieldErrs map[string]string) string {
+	fields := make([]string, 0, len(fieldErrs))
+	for field := range fieldErrs {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = fmt.Sprintf("%s %s", field, fieldErrs[field])
+	}
+	return strings.Join(parts, "; ")
+}
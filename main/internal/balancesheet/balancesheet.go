@@ -6,32 +6,46 @@ import (
 )
 
 type BalanceSheet struct {
-	Balances map[*entity.User]map[*entity.User]float64
+	Balances map[*entity.User]map[*entity.User]entity.Money
 }
 
 func NewBalanceSheet() *BalanceSheet {
 	return &BalanceSheet{
-		Balances: make(map[*entity.User]map[*entity.User]float64),
+		Balances: make(map[*entity.User]map[*entity.User]entity.Money),
 	}
 }
 
 func (b *BalanceSheet) UpdateBalance(paidBy *entity.User, splits []*entity.Split) {
 	for _, split := range splits {
 		if b.Balances[paidBy] == nil {
-			b.Balances[paidBy] = make(map[*entity.User]float64)
+			b.Balances[paidBy] = make(map[*entity.User]entity.Money)
 		}
 		if b.Balances[split.User] == nil {
-			b.Balances[split.User] = make(map[*entity.User]float64)
+			b.Balances[split.User] = make(map[*entity.User]entity.Money)
 		}
-		b.Balances[paidBy][split.User] -= split.Amount
-		b.Balances[split.User][paidBy] += split.Amount
+		b.Balances[paidBy][split.User] = b.Balances[paidBy][split.User].Sub(split.Amount)
+		b.Balances[split.User][paidBy] = b.Balances[split.User][paidBy].Add(split.Amount)
+	}
+}
+
+// UpdateBalanceForPayers records an expense paid by several members
+func (b *BalanceSheet) UpdateBalanceForPayers(payers []*entity.Payer, splits []*entity.Split) {
+	for _, debt := range ComputeDebts(payers, splits) {
+		b.UpdateBalance(debt.To, []*entity.Split{entity.NewSplit(debt.From, debt.Amount)})
+	}
+}
+
+// RevertBalanceForPayers takes a previously recorded expense back out
+func (b *BalanceSheet) RevertBalanceForPayers(payers []*entity.Payer, splits []*entity.Split) {
+	for _, debt := range ComputeDebts(payers, splits) {
+		b.UpdateBalance(debt.To, []*entity.Split{entity.NewSplit(debt.From, debt.Amount.Neg())})
 	}
 }
 
 func (b *BalanceSheet) PrintBalanceForUser(user *entity.User) {
 	for otherUser, amount := range b.Balances[user] {
 		if user.UserName != otherUser.UserName {
-			fmt.Printf("%s owes %s: %f\n", user.UserName, otherUser.UserName, amount)
+			fmt.Printf("%s owes %s: %s\n", user.UserName, otherUser.UserName, amount)
 		}
 	}
 }
@@ -40,19 +54,19 @@ func (b *BalanceSheet) PrintBalances() {
 	for user, balances := range b.Balances {
 		fmt.Printf("%s's BalancesSheet:\n", user.UserName)
 		for otherUser, amount := range balances {
-			fmt.Printf("\t%s: %f\n", otherUser.UserName, amount)
+			fmt.Printf("\t%s: %s\n", otherUser.UserName, amount)
 		}
 	}
 }
 
-func (b *BalanceSheet) SettleBalance(payer *entity.User, payee *entity.User, amount float64) {
-	b.Balances[payer][payee] -= amount
-	b.Balances[payee][payer] += amount
-	if b.Balances[payer][payee] == 0 {
+func (b *BalanceSheet) SettleBalance(payer *entity.User, payee *entity.User, amount entity.Money) {
+	b.Balances[payer][payee] = b.Balances[payer][payee].Sub(amount)
+	b.Balances[payee][payer] = b.Balances[payee][payer].Add(amount)
+	if b.Balances[payer][payee].IsZero() {
 		delete(b.Balances[payer], payee)
 		delete(b.Balances[payee], payer)
 	}
-	if b.Balances[payee][payer] == 0 {
+	if b.Balances[payee][payer].IsZero() {
 		delete(b.Balances[payee], payer)
 	}
 	if len(b.Balances[payer]) == 0 {
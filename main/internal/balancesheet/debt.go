@@ -0,0 +1,70 @@
+package balancesheet
+
+import (
+	"sort"
+	"splitwise/main/internal/entity"
+)
+
+// Debt is an amount one member owes another as a result of an expense
+type Debt struct {
+	From   *entity.User
+	To     *entity.User
+	Amount entity.Money
+}
+
+// ComputeDebts works out who owes whom for an expense. Each member's split is
+// divided between the payers in proportion to what they have left to be paid
+// back, so rounding never leaves a payer a cent over or under their
+// contribution. Debts running both ways between the same two members are
+// netted into one.
+func ComputeDebts(payers []*entity.Payer, splits []*entity.Split) []*Debt {
+	remaining := make([]int64, len(payers))
+	for i, payer := range payers {
+		remaining[i] = payer.Amount.Amount
+	}
+
+	// Net amounts keyed by member pair, positive when pair[0] owes pair[1]
+	users := make(map[string]*entity.User)
+	net := make(map[[2]string]entity.Money)
+	for _, split := range splits {
+		users[split.User.UserID] = split.User
+		for i, share := range split.Amount.AllocateByWeights(remaining) {
+			remaining[i] -= share.Amount
+			payer := payers[i].User
+			if payer.UserID == split.User.UserID || share.IsZero() {
+				continue
+			}
+			users[payer.UserID] = payer
+			if split.User.UserID < payer.UserID {
+				key := [2]string{split.User.UserID, payer.UserID}
+				net[key] = net[key].Add(share)
+			} else {
+				key := [2]string{payer.UserID, split.User.UserID}
+				net[key] = net[key].Sub(share)
+			}
+		}
+	}
+
+	keys := make([][2]string, 0, len(net))
+	for key := range net {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+
+	debts := make([]*Debt, 0)
+	for _, key := range keys {
+		amount := net[key]
+		switch {
+		case amount.IsPositive():
+			debts = append(debts, &Debt{From: users[key[0]], To: users[key[1]], Amount: amount})
+		case amount.IsNegative():
+			debts = append(debts, &Debt{From: users[key[1]], To: users[key[0]], Amount: amount.Neg()})
+		}
+	}
+	return debts
+}
@@ -0,0 +1,87 @@
+package balancesheet
+
+import (
+	"fmt"
+	"splitwise/main/internal/entity"
+	"testing"
+)
+
+func TestComputeDebts(t *testing.T) {
+	users := make(map[string]*entity.User)
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		users[id] = entity.NewUser(id, id, id+"@example.com")
+	}
+	usd := func(amount int64) entity.Money { return entity.NewMoney(amount, "USD") }
+
+	tests := []struct {
+		name   string
+		payers map[string]int64
+		splits map[string]int64
+		want   []string
+	}{
+		{
+			name:   "single payer",
+			payers: map[string]int64{"a": 900},
+			splits: map[string]int64{"a": 300, "b": 300, "c": 300},
+			want:   []string{"b->a 300", "c->a 300"},
+		},
+		{
+			name:   "payer is the only participant",
+			payers: map[string]int64{"a": 500},
+			splits: map[string]int64{"a": 500},
+			want:   []string{},
+		},
+		{
+			// a's share is partly owed to b, netted against what b owes a
+			name:   "two payers, uneven splits",
+			payers: map[string]int64{"a": 600, "b": 400},
+			splits: map[string]int64{"a": 100, "b": 300, "c": 600},
+			want:   []string{"b->a 140", "c->a 360", "c->b 240"},
+		},
+		{
+			// Every split rounds, yet each payer is paid back exactly
+			name:   "several payers, rounding",
+			payers: map[string]int64{"a": 1000, "b": 1},
+			splits: map[string]int64{"c": 334, "d": 334, "e": 333},
+			want:   []string{"c->a 334", "d->a 333", "e->a 333", "d->b 1"},
+		},
+		{
+			name:   "three payers",
+			payers: map[string]int64{"a": 300, "b": 300, "c": 300},
+			splits: map[string]int64{"a": 300, "b": 300, "c": 300},
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Built in ID order, the way expenses hand them over
+			payers := make([]*entity.Payer, 0)
+			splits := make([]*entity.Split, 0)
+			for _, id := range []string{"a", "b", "c", "d", "e"} {
+				if amount, ok := tt.payers[id]; ok {
+					payers = append(payers, entity.NewPayer(users[id], usd(amount)))
+				}
+				if amount, ok := tt.splits[id]; ok {
+					splits = append(splits, entity.NewSplit(users[id], usd(amount)))
+				}
+			}
+
+			debts := ComputeDebts(payers, splits)
+			got := make([]string, 0, len(debts))
+			net := make(map[string]int64)
+			for _, debt := range debts {
+				got = append(got, fmt.Sprintf("%s->%s %d", debt.From.UserID, debt.To.UserID, debt.Amount.Amount))
+				net[debt.To.UserID] += debt.Amount.Amount
+				net[debt.From.UserID] -= debt.Amount.Amount
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("debts = %v, want %v", got, tt.want)
+			}
+			for id := range users {
+				if want := tt.payers[id] - tt.splits[id]; net[id] != want {
+					t.Errorf("%s is owed %d net, want %d", id, net[id], want)
+				}
+			}
+		})
+	}
+}
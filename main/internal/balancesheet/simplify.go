@@ -0,0 +1,79 @@
+package balancesheet
+
+import (
+	"sort"
+	"splitwise/main/internal/entity"
+)
+
+// position is a member's net standing: positive when they are owed overall
+type position struct {
+	user   *entity.User
+	amount int64
+}
+
+// SimplifyDebts replaces a set of debts with fewer payments that leave every
+// member in the same net position. Each member's debts are netted, then the
+// largest debtor repeatedly pays the largest creditor until everyone is even.
+func SimplifyDebts(debts []*Debt) []*Debt {
+	if len(debts) == 0 {
+		return []*Debt{}
+	}
+	currency := debts[0].Amount.Currency
+
+	users := make(map[string]*entity.User)
+	net := make(map[string]int64)
+	for _, debt := range debts {
+		users[debt.From.UserID] = debt.From
+		users[debt.To.UserID] = debt.To
+		net[debt.From.UserID] -= debt.Amount.Amount
+		net[debt.To.UserID] += debt.Amount.Amount
+	}
+
+	creditors := make([]*position, 0)
+	debtors := make([]*position, 0)
+	for userID, amount := range net {
+		if amount > 0 {
+			creditors = append(creditors, &position{user: users[userID], amount: amount})
+		} else if amount < 0 {
+			debtors = append(debtors, &position{user: users[userID], amount: -amount})
+		}
+	}
+
+	simplified := make([]*Debt, 0)
+	for len(creditors) > 0 && len(debtors) > 0 {
+		sortPositions(creditors)
+		sortPositions(debtors)
+		creditor, debtor := creditors[0], debtors[0]
+
+		amount := creditor.amount
+		if debtor.amount < amount {
+			amount = debtor.amount
+		}
+		simplified = append(simplified, &Debt{
+			From:   debtor.user,
+			To:     creditor.user,
+			Amount: entity.NewMoney(amount, currency),
+		})
+
+		creditor.amount -= amount
+		debtor.amount -= amount
+		if creditor.amount == 0 {
+			creditors = creditors[1:]
+		}
+		if debtor.amount == 0 {
+			debtors = debtors[1:]
+		}
+	}
+	return simplified
+}
+
+// sortPositions orders positions largest first, by user ID on ties so the
+// result does not depend on map order
+func sortPositions(positions []*position) {
+	sort.Slice(positions, func(i, j int) bool {
+		if positions[i].amount != positions[j].amount {
+			return positions[i].amount > positions[j].amount
+		}
+		return positions[i].user.UserID < positions[j].user.UserID
+	})
+}
@@ -0,0 +1,41 @@
+package blobstore
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// Store keeps opaque blobs such as receipt files under string keys. Keys are
+// slash separated paths of letters, digits, '-', '_' and '.'.
+type Store interface {
+	// Put stores the contents of r under key, replacing any existing blob
+	Put(key string, r io.Reader) error
+	// Get opens the blob under key; it returns ErrNotFound if there is none
+	Get(key string) (io.ReadCloser, error)
+	// Delete removes the blob under key; deleting a missing blob is not an error
+	Delete(key string) error
+}
+
+// ValidKey reports whether key is safe to use with any Store
+func ValidKey(key string) bool {
+	if key == "" || len(key) > 255 {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+		for _, r := range part {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"sort"
+	"time"
+)
+
+// ActivityRecord is one entry in a group's activity feed: an expense or a
+// settlement
+type ActivityRecord struct {
+	Type       string            `json:"type"`
+	Date       time.Time         `json:"date"`
+	Expense    *ExpenseRecord    `json:"expense,omitempty"`
+	Settlement *SettlementRecord `json:"settlement,omitempty"`
+}
+
+// GetGroupActivity returns a group's expenses and settlements interleaved,
+// newest first
+func GetGroupActivity(groupID string) ([]ActivityRecord, error) {
+	expenses, err := GetGroupExpenses(groupID, ExpenseFilter{})
+	if err != nil {
+		return nil, err
+	}
+	settlements, err := GetGroupSettlements(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	activity := make([]ActivityRecord, 0, len(expenses)+len(settlements))
+	for i := range expenses {
+		activity = append(activity, ActivityRecord{Type: "expense", Date: expenseActivityDate(&expenses[i]), Expense: &expenses[i]})
+	}
+	for i := range settlements {
+		activity = append(activity, ActivityRecord{Type: "settlement", Date: settlements[i].DateCreated, Settlement: &settlements[i]})
+	}
+	sort.SliceStable(activity, func(i, j int) bool {
+		return activity[i].Date.After(activity[j].Date)
+	})
+	return activity, nil
+}
+
+// expenseActivityDate places an expense in the feed on the day it happened,
+// keeping the time it was entered when that was the same day
+func expenseActivityDate(expense *ExpenseRecord) time.Time {
+	if expense.DateCreated.Format(dateLayout) == expense.ExpenseDate {
+		return expense.DateCreated
+	}
+	if date, err := time.Parse(dateLayout, expense.ExpenseDate); err == nil {
+		return date
+	}
+	return expense.DateCreated
+}
@@ -1,42 +1,71 @@
 package db
 
-import "fmt"
-
-// UserHasPendingBalances checks if a user owes or is owed money
+import (
+	"fmt"
+	"splitwise/main/internal/entity"
+	"strings"
+)
+
+// UserHasPendingBalances checks if a user owes or is owed money. Groups keep
+// balances in their own base currency, so the amounts are totalled per
+// currency.
 func UserHasPendingBalances(userID string) (bool, string, error) {
 	// Check if user owes anyone
-	var owesCount int
-	var owesAmount float64
-	err := DB.QueryRow(`
-		SELECT COUNT(*), COALESCE(SUM(amount), 0) 
-		FROM balances 
-		WHERE from_user_id = ? AND amount > 0
-	`, userID).Scan(&owesCount, &owesAmount)
+	owes, err := pendingBalanceTotals("from_user_id", userID)
 	if err != nil {
 		return false, "", err
 	}
-	if owesCount > 0 {
-		return true, fmt.Sprintf("User owes $%.2f to others. Must settle up before deletion.", owesAmount), nil
+	if len(owes) > 0 {
+		return true, fmt.Sprintf("User owes %s to others. Must settle up before deletion.", joinMoney(owes)), nil
 	}
 
 	// Check if anyone owes this user
-	var owedCount int
-	var owedAmount float64
-	err = DB.QueryRow(`
-		SELECT COUNT(*), COALESCE(SUM(amount), 0) 
-		FROM balances 
-		WHERE to_user_id = ? AND amount > 0
-	`, userID).Scan(&owedCount, &owedAmount)
+	owed, err := pendingBalanceTotals("to_user_id", userID)
 	if err != nil {
 		return false, "", err
 	}
-	if owedCount > 0 {
-		return true, fmt.Sprintf("User is owed $%.2f by others. Must collect before deletion.", owedAmount), nil
+	if len(owed) > 0 {
+		return true, fmt.Sprintf("User is owed %s by others. Must collect before deletion.", joinMoney(owed)), nil
 	}
 
 	return false, "", nil
 }
 
+// pendingBalanceTotals sums the positive balances where column is userID,
+// one total per group base currency
+func pendingBalanceTotals(column, userID string) ([]entity.Money, error) {
+	rows, err := DB.Query(`
+		SELECT g.base_currency, SUM(b.amount)
+		FROM balances b
+		JOIN groups g ON b.group_id = g.group_id
+		WHERE b.`+column+` = ? AND b.amount > 0
+		GROUP BY g.base_currency
+		ORDER BY g.base_currency
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := make([]entity.Money, 0)
+	for rows.Next() {
+		total := entity.Money{}
+		if err := rows.Scan(&total.Currency, &total.Amount); err != nil {
+			return nil, err
+		}
+		totals = append(totals, total)
+	}
+	return totals, rows.Err()
+}
+
+func joinMoney(amounts []entity.Money) string {
+	parts := make([]string, len(amounts))
+	for i, amount := range amounts {
+		parts[i] = amount.String()
+	}
+	return strings.Join(parts, " and ")
+}
+
 // GroupHasUnsettledBalances checks if a group has any non-zero balances
 func GroupHasUnsettledBalances(groupID string) (bool, error) {
 	var count int
@@ -87,6 +116,20 @@ func DeleteGroup(groupID string) error {
 	}
 	defer tx.Rollback()
 
+	// Delete receipt items for expenses in this group
+	if err := deleteReceipts(tx, "expense_id IN (SELECT expense_id FROM expenses WHERE group_id = ?)", groupID); err != nil {
+		return err
+	}
+
+	// Delete payers for expenses in this group
+	_, err = tx.Exec(`
+		DELETE FROM expense_payers WHERE expense_id IN 
+		(SELECT expense_id FROM expenses WHERE group_id = ?)
+	`, groupID)
+	if err != nil {
+		return err
+	}
+
 	// Delete splits for expenses in this group
 	_, err = tx.Exec(`
 		DELETE FROM splits WHERE expense_id IN 
@@ -96,6 +139,15 @@ func DeleteGroup(groupID string) error {
 		return err
 	}
 
+	// Delete attachment records for expenses in this group
+	_, err = tx.Exec(`
+		DELETE FROM expense_attachments WHERE expense_id IN
+		(SELECT expense_id FROM expenses WHERE group_id = ?)
+	`, groupID)
+	if err != nil {
+		return err
+	}
+
 	// Delete expenses
 	_, err = tx.Exec("DELETE FROM expenses WHERE group_id = ?", groupID)
 	if err != nil {
@@ -108,6 +160,34 @@ func DeleteGroup(groupID string) error {
 		return err
 	}
 
+	// Delete settlements
+	_, err = tx.Exec("DELETE FROM settlements WHERE group_id = ?", groupID)
+	if err != nil {
+		return err
+	}
+
+	// Delete ledger entries
+	_, err = tx.Exec("DELETE FROM ledger_entries WHERE group_id = ?", groupID)
+	if err != nil {
+		return err
+	}
+
+	// Delete custom categories and their keyword rules
+	_, err = tx.Exec("DELETE FROM group_categories WHERE group_id = ?", groupID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM category_rules WHERE group_id = ?", groupID)
+	if err != nil {
+		return err
+	}
+
+	// Delete recurring expense templates
+	_, err = tx.Exec("DELETE FROM recurring_expenses WHERE group_id = ?", groupID)
+	if err != nil {
+		return err
+	}
+
 	// Delete group members
 	_, err = tx.Exec("DELETE FROM group_members WHERE group_id = ?", groupID)
 	if err != nil {
@@ -122,4 +202,3 @@ func DeleteGroup(groupID string) error {
 
 	return tx.Commit()
 }
-
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// AttachmentRecord is a file, such as a receipt photo, attached to an
+// expense. The file itself lives in the blob store under BlobKey.
+type AttachmentRecord struct {
+	AttachmentID string `json:"attachment_id"`
+	ExpenseID    string `json:"expense_id"`
+	FileName     string `json:"file_name"`
+	ContentType  string `json:"content_type"`
+	SizeBytes    int64  `json:"size_bytes"`
+	BlobKey      string `json:"-"`
+	// ThumbnailKey is empty for files without a preview, such as PDFs
+	ThumbnailKey string    `json:"-"`
+	HasThumbnail bool      `json:"has_thumbnail"`
+	UploadedBy   string    `json:"uploaded_by"`
+	DateCreated  time.Time `json:"date_created"`
+}
+
+// CreateAttachment records a file already written to the blob store
+func CreateAttachment(a *AttachmentRecord) error {
+	_, err := DB.Exec(`
+		INSERT INTO expense_attachments (attachment_id, expense_id, file_name, content_type, size_bytes, blob_key, thumbnail_key, uploaded_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, a.AttachmentID, a.ExpenseID, a.FileName, a.ContentType, a.SizeBytes, a.BlobKey, a.ThumbnailKey, a.UploadedBy)
+	return err
+}
+
+// GetAttachment returns a single attachment
+func GetAttachment(attachmentID string) (*AttachmentRecord, error) {
+	attachments, err := listAttachments("attachment_id = ?", attachmentID)
+	if err != nil {
+		return nil, err
+	}
+	if len(attachments) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &attachments[0], nil
+}
+
+// GetExpenseAttachments returns an expense's attachments, oldest first
+func GetExpenseAttachments(expenseID string) ([]AttachmentRecord, error) {
+	return listAttachments("expense_id = ?", expenseID)
+}
+
+// GetGroupAttachments returns the attachments of every expense in a group,
+// deleted or not
+func GetGroupAttachments(groupID string) ([]AttachmentRecord, error) {
+	return listAttachments("expense_id IN (SELECT expense_id FROM expenses WHERE group_id = ?)", groupID)
+}
+
+// DeleteAttachment removes an attachment's record; its blobs are the
+// caller's to remove
+func DeleteAttachment(attachmentID string) error {
+	_, err := DB.Exec("DELETE FROM expense_attachments WHERE attachment_id = ?", attachmentID)
+	return err
+}
+
+func listAttachments(condition string, args ...interface{}) ([]AttachmentRecord, error) {
+	rows, err := DB.Query(`
+		SELECT attachment_id, expense_id, file_name, content_type, size_bytes, blob_key, thumbnail_key, uploaded_by, date_created
+		FROM expense_attachments
+		WHERE `+condition+`
+		ORDER BY date_created, attachment_id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := make([]AttachmentRecord, 0)
+	for rows.Next() {
+		a := AttachmentRecord{}
+		if err := rows.Scan(
+			&a.AttachmentID, &a.ExpenseID, &a.FileName, &a.ContentType, &a.SizeBytes,
+			&a.BlobKey, &a.ThumbnailKey, &a.UploadedBy, &a.DateCreated,
+		); err != nil {
+			return nil, err
+		}
+		a.HasThumbnail = a.ThumbnailKey != ""
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
@@ -1,93 +1,94 @@
 package db
 
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"splitwise/main/internal/entity"
+	"time"
+)
+
 type BalanceRecord struct {
-	GroupID      string  `json:"group_id"`
-	FromUserID   string  `json:"from_user_id"`
-	FromUserName string  `json:"from_user_name"`
-	ToUserID     string  `json:"to_user_id"`
-	ToUserName   string  `json:"to_user_name"`
-	Amount       float64 `json:"amount"`
+	GroupID      string       `json:"group_id"`
+	GroupName    string       `json:"group_name"`
+	FromUserID   string       `json:"from_user_id"`
+	FromUserName string       `json:"from_user_name"`
+	ToUserID     string       `json:"to_user_id"`
+	ToUserName   string       `json:"to_user_name"`
+	Amount       entity.Money `json:"amount"`
+	// Currency is the group's base currency, which balances are kept in
+	Currency entity.Currency `json:"currency"`
 }
 
-func UpdateBalance(groupID, paidByUserID, splitUserID string, amount float64) error {
-	if paidByUserID == splitUserID {
-		return nil
-	}
-
-	tx, err := DB.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
+// applyBalance records that splitUser owes paidBy amount for an expense within
+// tx. A negative amount reverses an earlier update.
+func applyBalance(tx *sql.Tx, expenseID, groupID, paidByUserID, splitUserID string, amount entity.Money) error {
+	return appendLedgerEntry(tx, LedgerEntry{
+		GroupID:     groupID,
+		FromUserID:  splitUserID,
+		ToUserID:    paidByUserID,
+		Amount:      amount,
+		EntryType:   LedgerExpense,
+		ReferenceID: expenseID,
+	})
+}
 
-	// Update: splitUser owes paidBy
-	_, err = tx.Exec(`
+// adjustBalance adds amount to what fromUser owes toUser, keeping the mirrored
+// row in step. Only the ledger should call it.
+func adjustBalance(tx *sql.Tx, groupID, fromUserID, toUserID string, amount entity.Money) error {
+	// Update: fromUser owes toUser
+	_, err := tx.Exec(`
 		INSERT INTO balances (group_id, from_user_id, to_user_id, amount) 
 		VALUES (?, ?, ?, ?)
 		ON CONFLICT(group_id, from_user_id, to_user_id) 
 		DO UPDATE SET amount = amount + ?
-	`, groupID, splitUserID, paidByUserID, amount, amount)
+	`, groupID, fromUserID, toUserID, amount.Amount, amount.Amount)
 	if err != nil {
 		return err
 	}
 
-	// Update reverse: paidBy is owed by splitUser (negative)
+	// Update reverse: toUser is owed by fromUser (negative)
 	_, err = tx.Exec(`
 		INSERT INTO balances (group_id, from_user_id, to_user_id, amount) 
 		VALUES (?, ?, ?, ?)
 		ON CONFLICT(group_id, from_user_id, to_user_id) 
 		DO UPDATE SET amount = amount + ?
-	`, groupID, paidByUserID, splitUserID, -amount, -amount)
-	if err != nil {
-		return err
-	}
-
-	return tx.Commit()
+	`, groupID, toUserID, fromUserID, -amount.Amount, -amount.Amount)
+	return err
 }
 
-func SettleBalance(groupID, fromUserID, toUserID string, amount float64) error {
-	tx, err := DB.Begin()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
+// settleInTx applies a payment, or an offset, from fromUser to toUser to the
+// ledger and balances within tx
+func settleInTx(tx *sql.Tx, entryType, settlementID, groupID, fromUserID, toUserID string, amount entity.Money) error {
 	// Reduce what fromUser owes toUser
-	_, err = tx.Exec(`
-		UPDATE balances 
-		SET amount = amount - ? 
-		WHERE group_id = ? AND from_user_id = ? AND to_user_id = ?
-	`, amount, groupID, fromUserID, toUserID)
-	if err != nil {
-		return err
-	}
-
-	// Reduce what toUser is owed by fromUser
-	_, err = tx.Exec(`
-		UPDATE balances 
-		SET amount = amount + ? 
-		WHERE group_id = ? AND from_user_id = ? AND to_user_id = ?
-	`, amount, groupID, toUserID, fromUserID)
-	if err != nil {
-		return err
-	}
-
-	// Clean up zero/tiny balances
-	_, err = tx.Exec("DELETE FROM balances WHERE ABS(amount) < 0.10")
+	err := appendLedgerEntry(tx, LedgerEntry{
+		GroupID:     groupID,
+		FromUserID:  fromUserID,
+		ToUserID:    toUserID,
+		Amount:      amount.Neg(),
+		EntryType:   entryType,
+		ReferenceID: settlementID,
+	})
 	if err != nil {
 		return err
 	}
 
-	return tx.Commit()
+	// Clean up fully settled balances
+	_, err = tx.Exec(
+		"DELETE FROM balances WHERE group_id = ? AND amount = 0 AND from_user_id IN (?, ?) AND to_user_id IN (?, ?)",
+		groupID, fromUserID, toUserID, fromUserID, toUserID,
+	)
+	return err
 }
 
 func GetGroupBalances(groupID string) ([]BalanceRecord, error) {
 	rows, err := DB.Query(`
-		SELECT b.group_id, b.from_user_id, u1.user_name, b.to_user_id, u2.user_name, b.amount
+		SELECT b.group_id, g.group_name, b.from_user_id, u1.user_name, b.to_user_id, u2.user_name, b.amount, g.base_currency
 		FROM balances b
+		JOIN groups g ON b.group_id = g.group_id
 		JOIN users u1 ON b.from_user_id = u1.user_id
 		JOIN users u2 ON b.to_user_id = u2.user_id
-		WHERE b.group_id = ? AND b.amount > 0.10
+		WHERE b.group_id = ? AND b.amount > 0
 	`, groupID)
 	if err != nil {
 		return nil, err
@@ -97,9 +98,10 @@ func GetGroupBalances(groupID string) ([]BalanceRecord, error) {
 	balances := make([]BalanceRecord, 0)
 	for rows.Next() {
 		b := BalanceRecord{}
-		if err := rows.Scan(&b.GroupID, &b.FromUserID, &b.FromUserName, &b.ToUserID, &b.ToUserName, &b.Amount); err != nil {
+		if err := rows.Scan(&b.GroupID, &b.GroupName, &b.FromUserID, &b.FromUserName, &b.ToUserID, &b.ToUserName, &b.Amount.Amount, &b.Currency); err != nil {
 			return nil, err
 		}
+		b.Amount.Currency = b.Currency
 		balances = append(balances, b)
 	}
 	return balances, nil
@@ -107,8 +109,9 @@ func GetGroupBalances(groupID string) ([]BalanceRecord, error) {
 
 func GetUserBalances(userID string) ([]BalanceRecord, error) {
 	rows, err := DB.Query(`
-		SELECT b.group_id, b.from_user_id, u1.user_name, b.to_user_id, u2.user_name, b.amount
+		SELECT b.group_id, g.group_name, b.from_user_id, u1.user_name, b.to_user_id, u2.user_name, b.amount, g.base_currency
 		FROM balances b
+		JOIN groups g ON b.group_id = g.group_id
 		JOIN users u1 ON b.from_user_id = u1.user_id
 		JOIN users u2 ON b.to_user_id = u2.user_id
 		WHERE (b.from_user_id = ? OR b.to_user_id = ?) AND b.amount != 0
@@ -121,53 +124,90 @@ func GetUserBalances(userID string) ([]BalanceRecord, error) {
 	balances := make([]BalanceRecord, 0)
 	for rows.Next() {
 		b := BalanceRecord{}
-		if err := rows.Scan(&b.GroupID, &b.FromUserID, &b.FromUserName, &b.ToUserID, &b.ToUserName, &b.Amount); err != nil {
+		if err := rows.Scan(&b.GroupID, &b.GroupName, &b.FromUserID, &b.FromUserName, &b.ToUserID, &b.ToUserName, &b.Amount.Amount, &b.Currency); err != nil {
 			return nil, err
 		}
+		b.Amount.Currency = b.Currency
 		balances = append(balances, b)
 	}
 	return balances, nil
 }
 
 type BalanceSummary struct {
-	TotalYouOwe   float64 `json:"total_you_owe"`
-	TotalOwedToYou float64 `json:"total_owed_to_you"`
-	NetBalance    float64 `json:"net_balance"`
+	TotalYouOwe    entity.Money    `json:"total_you_owe"`
+	TotalOwedToYou entity.Money    `json:"total_owed_to_you"`
+	NetBalance     entity.Money    `json:"net_balance"`
+	Currency       entity.Currency `json:"currency"`
+	// Unconverted lists net amounts in currencies with no stored rate to
+	// Currency, which are left out of the totals
+	Unconverted []CurrencyAmount `json:"unconverted,omitempty"`
 }
 
+// CurrencyAmount is an amount together with the currency it is in
+type CurrencyAmount struct {
+	Amount   entity.Money    `json:"amount"`
+	Currency entity.Currency `json:"currency"`
+}
+
+// GetUserBalanceSummary totals what the user owes and is owed across all
+// groups, converting each group's base currency into the default currency at
+// today's exchange rate
 func GetUserBalanceSummary(userID string) (*BalanceSummary, error) {
-	summary := &BalanceSummary{}
-
-	// Total you owe to others
-	err := DB.QueryRow(`
-		SELECT COALESCE(SUM(amount), 0) 
-		FROM balances 
-		WHERE from_user_id = ? AND amount > 0.10
-	`, userID).Scan(&summary.TotalYouOwe)
+	summary := &BalanceSummary{
+		TotalYouOwe:    entity.NewMoney(0, entity.DefaultCurrency),
+		TotalOwedToYou: entity.NewMoney(0, entity.DefaultCurrency),
+		Currency:       entity.DefaultCurrency,
+	}
+
+	// What you owe (amount > 0) and are owed (amount < 0), per currency
+	rows, err := DB.Query(`
+		SELECT g.base_currency,
+			   COALESCE(SUM(CASE WHEN b.amount > 0 THEN b.amount END), 0),
+			   COALESCE(SUM(CASE WHEN b.amount < 0 THEN -b.amount END), 0)
+		FROM balances b
+		JOIN groups g ON b.group_id = g.group_id
+		WHERE b.from_user_id = ? AND b.amount != 0
+		GROUP BY g.base_currency
+		ORDER BY g.base_currency
+	`, userID)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	// Total others owe you
-	err = DB.QueryRow(`
-		SELECT COALESCE(SUM(amount), 0) 
-		FROM balances 
-		WHERE to_user_id = ? AND amount > 0.10
-	`, userID).Scan(&summary.TotalOwedToYou)
-	if err != nil {
+	for rows.Next() {
+		var currency entity.Currency
+		var owe, owed int64
+		if err := rows.Scan(&currency, &owe, &owed); err != nil {
+			return nil, err
+		}
+		rate, err := GetExchangeRate(currency, summary.Currency, time.Now())
+		if err == ErrNoExchangeRate {
+			net := entity.NewMoney(owed-owe, currency)
+			summary.Unconverted = append(summary.Unconverted, CurrencyAmount{Amount: net, Currency: currency})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		summary.TotalYouOwe = summary.TotalYouOwe.Add(entity.NewMoney(owe, currency).Convert(rate, summary.Currency))
+		summary.TotalOwedToYou = summary.TotalOwedToYou.Add(entity.NewMoney(owed, currency).Convert(rate, summary.Currency))
+	}
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
 	// Net balance (positive = you're owed, negative = you owe)
-	summary.NetBalance = summary.TotalOwedToYou - summary.TotalYouOwe
+	summary.NetBalance = summary.TotalOwedToYou.Sub(summary.TotalYouOwe)
 
 	return summary, nil
 }
 
 func GetAllBalances() ([]BalanceRecord, error) {
 	rows, err := DB.Query(`
-		SELECT b.group_id, b.from_user_id, u1.user_name, b.to_user_id, u2.user_name, b.amount
+		SELECT b.group_id, g.group_name, b.from_user_id, u1.user_name, b.to_user_id, u2.user_name, b.amount, g.base_currency
 		FROM balances b
+		JOIN groups g ON b.group_id = g.group_id
 		JOIN users u1 ON b.from_user_id = u1.user_id
 		JOIN users u2 ON b.to_user_id = u2.user_id
 		WHERE b.amount != 0
@@ -180,10 +220,260 @@ func GetAllBalances() ([]BalanceRecord, error) {
 	balances := make([]BalanceRecord, 0)
 	for rows.Next() {
 		b := BalanceRecord{}
-		if err := rows.Scan(&b.GroupID, &b.FromUserID, &b.FromUserName, &b.ToUserID, &b.ToUserName, &b.Amount); err != nil {
+		if err := rows.Scan(&b.GroupID, &b.GroupName, &b.FromUserID, &b.FromUserName, &b.ToUserID, &b.ToUserName, &b.Amount.Amount, &b.Currency); err != nil {
 			return nil, err
 		}
+		b.Amount.Currency = b.Currency
 		balances = append(balances, b)
 	}
 	return balances, nil
 }
+
+// GroupBalance is what a user owes a counterparty within one group; negative
+// when the counterparty owes them
+type GroupBalance struct {
+	GroupID   string       `json:"group_id"`
+	GroupName string       `json:"group_name"`
+	Amount    entity.Money `json:"amount"`
+}
+
+// CounterpartyBalance is a user's position against one other user netted
+// across every group they share with the same base currency. NetAmount is
+// positive when the user owes.
+type CounterpartyBalance struct {
+	UserID    string          `json:"user_id"`
+	UserName  string          `json:"user_name"`
+	NetAmount entity.Money    `json:"net_amount"`
+	Currency  entity.Currency `json:"currency"`
+	Groups    []GroupBalance  `json:"groups"`
+}
+
+// GroupSettlement is the part of a cross-group settlement applied to one group
+type GroupSettlement struct {
+	SettlementID string `json:"settlement_id"`
+	// Kind is SettlementOffset for a debt toUser owed that is cancelled
+	// rather than paid
+	Kind       string       `json:"kind"`
+	GroupID    string       `json:"group_id"`
+	FromUserID string       `json:"from_user_id"`
+	ToUserID   string       `json:"to_user_id"`
+	Amount     entity.Money `json:"amount"`
+}
+
+var (
+	ErrNothingOwed          = errors.New("nothing is owed to this user across shared groups")
+	ErrNetSettlementPending = errors.New("settlements waiting for confirmation already cover the net balance")
+	ErrAmountExceedsNet     = errors.New("amount is more than the net balance")
+)
+
+// pairBalance is what one user owes another in a group, as stored and with
+// pending settlements between them taken off
+type pairBalance struct {
+	groupID string
+	stored  int64
+	balance int64
+}
+
+// pairGroupBalances returns what fromUser owes toUser in each shared group
+// with the given base currency, largest debt first
+func pairGroupBalances(tx *sql.Tx, fromUserID, toUserID string, currency entity.Currency) ([]pairBalance, error) {
+	byGroup := make(map[string]*pairBalance)
+	addRows := func(query string, args ...interface{}) error {
+		rows, err := tx.Query(query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var groupID string
+			var stored, pending int64
+			if err := rows.Scan(&groupID, &stored, &pending); err != nil {
+				return err
+			}
+			b, ok := byGroup[groupID]
+			if !ok {
+				b = &pairBalance{groupID: groupID}
+				byGroup[groupID] = b
+			}
+			b.stored += stored
+			b.balance += stored - pending
+		}
+		return rows.Err()
+	}
+
+	err := addRows(`
+		SELECT b.group_id, b.amount, 0 FROM balances b
+		JOIN groups g ON b.group_id = g.group_id
+		WHERE b.from_user_id = ? AND b.to_user_id = ? AND b.amount != 0 AND g.base_currency = ?
+	`, fromUserID, toUserID, currency)
+	if err != nil {
+		return nil, err
+	}
+	// A pending payment from fromUser lowers their debt; one from toUser raises it
+	err = addRows(`
+		SELECT s.group_id, 0, SUM(CASE WHEN s.from_user_id = ? THEN s.amount ELSE -s.amount END)
+		FROM settlements s
+		JOIN groups g ON s.group_id = g.group_id
+		WHERE s.status = ? AND g.base_currency = ?
+			AND ((s.from_user_id = ? AND s.to_user_id = ?) OR (s.from_user_id = ? AND s.to_user_id = ?))
+		GROUP BY s.group_id
+	`, fromUserID, SettlementPending, currency, fromUserID, toUserID, toUserID, fromUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make([]pairBalance, 0, len(byGroup))
+	for _, b := range byGroup {
+		balances = append(balances, *b)
+	}
+	sort.Slice(balances, func(i, j int) bool {
+		if balances[i].balance != balances[j].balance {
+			return balances[i].balance > balances[j].balance
+		}
+		return balances[i].groupID < balances[j].groupID
+	})
+	return balances, nil
+}
+
+// GetUserNetBalances nets the user's balances from GetUserBalances per
+// counterparty and currency across all shared groups
+func GetUserNetBalances(userID string) ([]CounterpartyBalance, error) {
+	balances, err := GetUserBalances(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	byUser := make(map[string]*CounterpartyBalance)
+	order := make([]string, 0)
+	for _, b := range balances {
+		// Each pair is stored both ways; the user's own side is enough
+		if b.FromUserID != userID {
+			continue
+		}
+		// Amounts in different currencies cannot be netted
+		key := b.ToUserID + "/" + string(b.Currency)
+		counterparty, ok := byUser[key]
+		if !ok {
+			counterparty = &CounterpartyBalance{
+				UserID:    b.ToUserID,
+				UserName:  b.ToUserName,
+				NetAmount: entity.NewMoney(0, b.Currency),
+				Currency:  b.Currency,
+				Groups:    make([]GroupBalance, 0),
+			}
+			byUser[key] = counterparty
+			order = append(order, key)
+		}
+		counterparty.NetAmount = counterparty.NetAmount.Add(b.Amount)
+		counterparty.Groups = append(counterparty.Groups, GroupBalance{
+			GroupID:   b.GroupID,
+			GroupName: b.GroupName,
+			Amount:    b.Amount,
+		})
+	}
+
+	sort.Strings(order)
+	result := make([]CounterpartyBalance, 0, len(order))
+	for _, key := range order {
+		result = append(result, *byUser[key])
+	}
+	return result, nil
+}
+
+// SettleAcrossGroups records fromUser paying toUser amount against their net
+// balance across all shared groups whose base currency is amount's. Debts
+// toUser owes fromUser are first offset against fromUser's debts, recorded as
+// SettlementOffset parts rather than payments, then the payment covers the
+// rest, largest group debt first. Settlements between the
+// two that are still pending count as if they were confirmed, so the same
+// debt cannot be paid twice. amount may not exceed the net balance; a zero
+// amount pays all of it. Each group's part is stored as its own pending
+// settlement in a batch under settlementID, which toUser confirms as a whole.
+// It returns the amount paid.
+func SettleAcrossGroups(settlementID, fromUserID, toUserID string, amount entity.Money, note, method string) (entity.Money, []GroupSettlement, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return entity.Money{}, nil, err
+	}
+	defer tx.Rollback()
+
+	balances, err := pairGroupBalances(tx, fromUserID, toUserID, amount.Currency)
+	if err != nil {
+		return entity.Money{}, nil, err
+	}
+	owed := make([]GroupSettlement, 0)
+	credits := make([]GroupSettlement, 0)
+	var net, storedNet int64
+	for _, b := range balances {
+		net += b.balance
+		storedNet += b.stored
+		if b.balance > 0 {
+			owed = append(owed, GroupSettlement{Kind: SettlementPayment, GroupID: b.groupID, FromUserID: fromUserID, ToUserID: toUserID, Amount: entity.NewMoney(b.balance, amount.Currency)})
+		} else if b.balance < 0 {
+			credits = append(credits, GroupSettlement{Kind: SettlementOffset, GroupID: b.groupID, FromUserID: toUserID, ToUserID: fromUserID, Amount: entity.NewMoney(-b.balance, amount.Currency)})
+		}
+	}
+
+	if net <= 0 {
+		if storedNet > 0 {
+			return entity.Money{}, nil, ErrNetSettlementPending
+		}
+		return entity.Money{}, nil, ErrNothingOwed
+	}
+	if amount.IsZero() {
+		amount.Amount = net
+	}
+	if amount.Amount > net {
+		return entity.Money{}, nil, ErrAmountExceedsNet
+	}
+
+	// Offsets clear every group where toUser owes, and count towards
+	// fromUser's debts together with the payment
+	settlements := make([]GroupSettlement, 0, len(owed)+len(credits))
+	available := amount.Amount
+	for _, credit := range credits {
+		available += credit.Amount.Amount
+		settlements = append(settlements, credit)
+	}
+	for _, debt := range owed {
+		if available == 0 {
+			break
+		}
+		if debt.Amount.Amount > available {
+			debt.Amount.Amount = available
+		}
+		available -= debt.Amount.Amount
+		settlements = append(settlements, debt)
+	}
+
+	for i := range settlements {
+		settlement := &settlements[i]
+		settlement.SettlementID = partSettlementID(settlementID, i)
+		// No money changes hands for an offset, so it has no payment method
+		partMethod := method
+		if settlement.Kind == SettlementOffset {
+			partMethod = ""
+		}
+		err := insertSettlement(tx, &SettlementRecord{
+			SettlementID: settlement.SettlementID,
+			GroupID:      settlement.GroupID,
+			FromUserID:   settlement.FromUserID,
+			ToUserID:     settlement.ToUserID,
+			Amount:       settlement.Amount,
+			Note:         note,
+			Method:       partMethod,
+			Mode:         SettlePartial,
+			RecordedBy:   fromUserID,
+			BatchID:      settlementID,
+			Kind:         settlement.Kind,
+		})
+		if err != nil {
+			return entity.Money{}, nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return entity.Money{}, nil, err
+	}
+	return amount, settlements, nil
+}
@@ -0,0 +1,226 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrUnknownCategory = errors.New("category is neither built in nor defined by the group")
+	ErrCategoryExists  = errors.New("category already exists")
+)
+
+// DefaultCategory is used when an expense has no category and none of the
+// keyword rules match its description
+const DefaultCategory = "Other"
+
+// BuiltinCategories are available in every group
+var BuiltinCategories = []string{
+	"Food", "Groceries", "Rent", "Travel", "Transport", "Utilities",
+	"Entertainment", "Shopping", "Health", DefaultCategory,
+}
+
+// defaultCategoryRules seed the keyword rules shared by all groups
+var defaultCategoryRules = map[string][]string{
+	"Food":          {"dinner", "lunch", "breakfast", "brunch", "restaurant", "cafe", "coffee", "pizza", "burger", "sushi", "takeout", "snacks", "drinks", "bar", "pub"},
+	"Groceries":     {"grocery", "groceries", "supermarket", "market", "costco", "walmart", "aldi", "lidl", "tesco"},
+	"Rent":          {"rent", "lease", "deposit", "landlord", "mortgage"},
+	"Travel":        {"flight", "flights", "airbnb", "hotel", "hostel", "booking", "trip", "visa", "luggage"},
+	"Transport":     {"uber", "lyft", "taxi", "cab", "bus", "train", "metro", "subway", "fuel", "gas", "petrol", "parking", "toll"},
+	"Utilities":     {"electricity", "electric", "water", "internet", "wifi", "broadband", "phone", "heating", "power bill"},
+	"Entertainment": {"movie", "movies", "cinema", "concert", "tickets", "netflix", "spotify", "games", "museum"},
+	"Shopping":      {"amazon", "clothes", "shoes", "furniture", "ikea", "gift", "gifts"},
+	"Health":        {"pharmacy", "doctor", "dentist", "medicine", "gym", "hospital"},
+}
+
+// Category is one category a group's expenses can be filed under
+type Category struct {
+	Name   string `json:"name"`
+	Custom bool   `json:"custom"`
+	// Keywords are the group's own rules for suggesting this category
+	Keywords []string `json:"keywords,omitempty"`
+}
+
+// seedCategoryRules stores the default keyword rules, leaving any that were
+// already stored alone
+func seedCategoryRules() error {
+	for category, keywords := range defaultCategoryRules {
+		for _, keyword := range keywords {
+			if _, err := DB.Exec(
+				"INSERT OR IGNORE INTO category_rules (group_id, keyword, category) VALUES ('', ?, ?)",
+				keyword, category,
+			); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// GetGroupCategories returns the built-in categories followed by the group's
+// custom ones
+func GetGroupCategories(groupID string) ([]Category, error) {
+	categories := make([]Category, 0, len(BuiltinCategories))
+	for _, name := range BuiltinCategories {
+		categories = append(categories, Category{Name: name})
+	}
+
+	rows, err := DB.Query("SELECT name FROM group_categories WHERE group_id = ? ORDER BY name", groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	custom := make(map[string]int)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		custom[name] = len(categories)
+		categories = append(categories, Category{Name: name, Custom: true})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	// Attach the group's keyword rules to the categories they suggest
+	rules, err := DB.Query("SELECT keyword, category FROM category_rules WHERE group_id = ? ORDER BY keyword", groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rules.Close()
+	for rules.Next() {
+		var keyword, category string
+		if err := rules.Scan(&keyword, &category); err != nil {
+			return nil, err
+		}
+		if i, ok := custom[category]; ok {
+			categories[i].Keywords = append(categories[i].Keywords, keyword)
+		}
+	}
+	return categories, rules.Err()
+}
+
+// CreateGroupCategory adds a custom category to a group, along with keywords
+// that suggest it for new expenses. Keywords without any letters or digits
+// would match every description, so they are skipped.
+func CreateGroupCategory(groupID, name string, keywords []string) error {
+	if _, err := ResolveCategory(groupID, name); err == nil {
+		return ErrCategoryExists
+	} else if err != ErrUnknownCategory {
+		return err
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("INSERT INTO group_categories (group_id, name) VALUES (?, ?)", groupID, name); err != nil {
+		return err
+	}
+	for _, keyword := range keywords {
+		keyword = normalizeKeywords(keyword)
+		if keyword == "" {
+			continue
+		}
+		_, err := tx.Exec(`
+			INSERT INTO category_rules (group_id, keyword, category) VALUES (?, ?, ?)
+			ON CONFLICT(group_id, keyword) DO UPDATE SET category = excluded.category
+		`, groupID, keyword, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ResolveCategory returns the canonical spelling of a category the group can
+// use, matching names case-insensitively
+func ResolveCategory(groupID, name string) (string, error) {
+	for _, builtin := range BuiltinCategories {
+		if strings.EqualFold(builtin, name) {
+			return builtin, nil
+		}
+	}
+
+	var canonical string
+	err := DB.QueryRow(
+		"SELECT name FROM group_categories WHERE group_id = ? AND name = ? COLLATE NOCASE",
+		groupID, name,
+	).Scan(&canonical)
+	if err == sql.ErrNoRows {
+		return "", ErrUnknownCategory
+	}
+	if err != nil {
+		return "", err
+	}
+	return canonical, nil
+}
+
+// SuggestCategory picks a category for an expense description from the
+// keyword rules. The group's own rules win over the shared ones and longer
+// keywords over shorter ones; DefaultCategory is returned when none match.
+func SuggestCategory(groupID, description string) (string, error) {
+	rows, err := DB.Query(
+		"SELECT group_id, keyword, category FROM category_rules WHERE group_id IN ('', ?) AND keyword != ''",
+		groupID,
+	)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	type rule struct {
+		own      bool
+		keyword  string
+		category string
+	}
+	rules := make([]rule, 0)
+	for rows.Next() {
+		var ruleGroup string
+		r := rule{}
+		if err := rows.Scan(&ruleGroup, &r.keyword, &r.category); err != nil {
+			return "", err
+		}
+		r.own = ruleGroup != ""
+		rules = append(rules, r)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].own != rules[j].own {
+			return rules[i].own
+		}
+		if len(rules[i].keyword) != len(rules[j].keyword) {
+			return len(rules[i].keyword) > len(rules[j].keyword)
+		}
+		return rules[i].keyword < rules[j].keyword
+	})
+
+	// Keywords match whole words, so "bar" does not match "barber"
+	words := " " + normalizeKeywords(description) + " "
+	for _, r := range rules {
+		if strings.Contains(words, " "+r.keyword+" ") {
+			return r.category, nil
+		}
+	}
+	return DefaultCategory, nil
+}
+
+// normalizeKeywords lowercases text and collapses everything but letters and
+// digits into single spaces
+func normalizeKeywords(text string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}), " ")
+}
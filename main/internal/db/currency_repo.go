@@ -0,0 +1,209 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"splitwise/main/internal/entity"
+	"time"
+)
+
+var (
+	ErrNoExchangeRate    = errors.New("no exchange rate is stored for this currency pair")
+	ErrBaseCurrencyInUse = errors.New("base currency cannot change once the group has expenses or settlements")
+)
+
+// ReferenceCurrency is the currency published reference rates are quoted
+// against; pairs without a rate of their own are crossed through it
+const ReferenceCurrency entity.Currency = "EUR"
+
+// ExchangeRate says how many units of ToCurrency one unit of FromCurrency
+// bought on RateDate
+type ExchangeRate struct {
+	FromCurrency entity.Currency `json:"from_currency"`
+	ToCurrency   entity.Currency `json:"to_currency"`
+	RateDate     string          `json:"rate_date"`
+	Rate         float64         `json:"rate"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+}
+
+// SetExchangeRate stores the rate for a pair on a date, replacing any earlier
+// rate for the same pair and date
+func SetExchangeRate(from, to entity.Currency, date time.Time, rate float64) error {
+	_, err := ImportExchangeRates([]ExchangeRate{{
+		FromCurrency: from,
+		ToCurrency:   to,
+		RateDate:     date.Format(dateLayout),
+		Rate:         rate,
+	}})
+	return err
+}
+
+// ImportExchangeRates stores a batch of dated rates in one transaction and
+// returns how many were stored. Expenses keep the rate they were recorded at,
+// so loading rates, even for past dates, never changes existing balances.
+func ImportExchangeRates(rates []ExchangeRate) (int, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO exchange_rates (from_currency, to_currency, rate_date, rate)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(from_currency, to_currency, rate_date)
+		DO UPDATE SET rate = excluded.rate, updated_at = CURRENT_TIMESTAMP
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	for _, rate := range rates {
+		if _, err := stmt.Exec(rate.FromCurrency, rate.ToCurrency, rate.RateDate, rate.Rate); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(rates), nil
+}
+
+// GetExchangeRate returns how many units of to one unit of from bought on a
+// date, using the latest rate stored on or before it. A pair with no rate of
+// its own, either way round, is crossed through ReferenceCurrency.
+func GetExchangeRate(from, to entity.Currency, on time.Time) (float64, error) {
+	date := on.Format(dateLayout)
+	rate, ok, err := pairRate(from, to, date)
+	if err != nil || ok {
+		return rate, err
+	}
+
+	toReference, ok, err := pairRate(from, ReferenceCurrency, date)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrNoExchangeRate
+	}
+	fromReference, ok, err := pairRate(ReferenceCurrency, to, date)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrNoExchangeRate
+	}
+	return toReference * fromReference, nil
+}
+
+// pairRate looks up a pair's rate on date directly, or as the inverse of the
+// opposite pair
+func pairRate(from, to entity.Currency, date string) (float64, bool, error) {
+	if from == to {
+		return 1, true, nil
+	}
+	rate, ok, err := storedRate(from, to, date)
+	if err != nil || ok {
+		return rate, ok, err
+	}
+	rate, ok, err = storedRate(to, from, date)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+	return 1 / rate, true, nil
+}
+
+// storedRate returns the latest rate stored for the pair on or before date
+func storedRate(from, to entity.Currency, date string) (float64, bool, error) {
+	var rate float64
+	err := DB.QueryRow(`
+		SELECT rate FROM exchange_rates
+		WHERE from_currency = ? AND to_currency = ? AND rate_date <= ?
+		ORDER BY rate_date DESC
+		LIMIT 1
+	`, from, to, date).Scan(&rate)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return rate, rate > 0, nil
+}
+
+// GetExchangeRates lists the latest stored rate of every pair
+func GetExchangeRates() ([]ExchangeRate, error) {
+	return listExchangeRates(`
+		rate_date = (
+			SELECT MAX(latest.rate_date) FROM exchange_rates latest
+			WHERE latest.from_currency = r.from_currency AND latest.to_currency = r.to_currency
+		)
+	`)
+}
+
+// GetExchangeRateHistory lists every stored rate of one pair, newest first
+func GetExchangeRateHistory(from, to entity.Currency) ([]ExchangeRate, error) {
+	return listExchangeRates("from_currency = ? AND to_currency = ?", from, to)
+}
+
+func listExchangeRates(condition string, args ...interface{}) ([]ExchangeRate, error) {
+	rows, err := DB.Query(`
+		SELECT from_currency, to_currency, rate_date, rate, updated_at
+		FROM exchange_rates r
+		WHERE `+condition+`
+		ORDER BY from_currency, to_currency, rate_date DESC
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := make([]ExchangeRate, 0)
+	for rows.Next() {
+		rate := ExchangeRate{}
+		if err := rows.Scan(&rate.FromCurrency, &rate.ToCurrency, &rate.RateDate, &rate.Rate, &rate.UpdatedAt); err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
+
+// SetGroupBaseCurrency changes the currency a group keeps its balances in. It
+// is only allowed before anything has touched the group's ledger, since
+// existing balances would otherwise change meaning.
+func SetGroupBaseCurrency(groupID string, currency entity.Currency) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var current entity.Currency
+	if err := tx.QueryRow("SELECT base_currency FROM groups WHERE group_id = ?", groupID).Scan(&current); err != nil {
+		return err
+	}
+	if current == currency {
+		return nil
+	}
+
+	var used int
+	err = tx.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM expenses WHERE group_id = ?)
+			 + (SELECT COUNT(*) FROM settlements WHERE group_id = ?)
+			 + (SELECT COUNT(*) FROM ledger_entries WHERE group_id = ?)
+	`, groupID, groupID, groupID).Scan(&used)
+	if err != nil {
+		return err
+	}
+	if used > 0 {
+		return ErrBaseCurrencyInUse
+	}
+
+	if _, err := tx.Exec("UPDATE groups SET base_currency = ? WHERE group_id = ?", currency, groupID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -4,12 +4,22 @@ import (
 	"database/sql"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 var DB *sql.DB
 
+// dateLayout is how calendar dates such as expense, rate and schedule dates
+// are stored, so they sort as text
+const dateLayout = "2006-01-02"
+
+// DataDir is the directory holding the database file; other local data, such
+// as expense attachments, is kept under it by default
+var DataDir string
+
 func Init() error {
 	var err error
 
@@ -18,6 +28,7 @@ func Init() error {
 	if dbURL == "" {
 		dbURL = "./splitwise.db"
 	}
+	DataDir = filepath.Dir(strings.TrimPrefix(strings.SplitN(dbURL, "?", 2)[0], "file:"))
 
 	DB, err = sql.Open("sqlite3", dbURL)
 	if err != nil {
@@ -29,77 +40,419 @@ func Init() error {
 		return err
 	}
 
+	// Bring databases created by older versions up to date
+	if err := migrate(); err != nil {
+		return err
+	}
+
 	log.Println("✅ Database initialized")
 	return nil
 }
 
+// Amounts are stored as INTEGER minor units (see entity.Money)
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS users (
+		user_id TEXT PRIMARY KEY,
+		user_name TEXT NOT NULL,
+		user_email TEXT UNIQUE NOT NULL,
+		password_hash TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS groups (
+		group_id TEXT PRIMARY KEY,
+		group_name TEXT NOT NULL,
+		created_by TEXT NOT NULL,
+		date_created DATETIME DEFAULT CURRENT_TIMESTAMP,
+		simplify_debts INTEGER NOT NULL DEFAULT 0,
+		base_currency TEXT NOT NULL DEFAULT 'USD',
+		FOREIGN KEY (created_by) REFERENCES users(user_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS group_members (
+		group_id TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		PRIMARY KEY (group_id, user_id),
+		FOREIGN KEY (group_id) REFERENCES groups(group_id),
+		FOREIGN KEY (user_id) REFERENCES users(user_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS expenses (
+		expense_id TEXT PRIMARY KEY,
+		expense_description TEXT NOT NULL,
+		expense_amount INTEGER NOT NULL,
+		currency TEXT NOT NULL DEFAULT 'USD',
+		exchange_rate REAL NOT NULL DEFAULT 1,
+		category TEXT NOT NULL DEFAULT 'Other',
+		expense_date TEXT NOT NULL DEFAULT '',
+		group_id TEXT NOT NULL,
+		paid_by_user_id TEXT NOT NULL,
+		date_created DATETIME DEFAULT CURRENT_TIMESTAMP,
+		deleted_at DATETIME,
+		FOREIGN KEY (group_id) REFERENCES groups(group_id),
+		FOREIGN KEY (paid_by_user_id) REFERENCES users(user_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS splits (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		expense_id TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		amount INTEGER NOT NULL,
+		FOREIGN KEY (expense_id) REFERENCES expenses(expense_id),
+		FOREIGN KEY (user_id) REFERENCES users(user_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS expense_payers (
+		expense_id TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		amount INTEGER NOT NULL,
+		PRIMARY KEY (expense_id, user_id),
+		FOREIGN KEY (expense_id) REFERENCES expenses(expense_id),
+		FOREIGN KEY (user_id) REFERENCES users(user_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS expense_receipts (
+		expense_id TEXT PRIMARY KEY,
+		tax_amount INTEGER NOT NULL DEFAULT 0,
+		tip_amount INTEGER NOT NULL DEFAULT 0,
+		FOREIGN KEY (expense_id) REFERENCES expenses(expense_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS expense_items (
+		item_id INTEGER PRIMARY KEY AUTOINCREMENT,
+		expense_id TEXT NOT NULL,
+		item_name TEXT NOT NULL,
+		unit_price INTEGER NOT NULL,
+		quantity INTEGER NOT NULL DEFAULT 1,
+		split_type TEXT NOT NULL,
+		FOREIGN KEY (expense_id) REFERENCES expenses(expense_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS expense_item_splits (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		item_id INTEGER NOT NULL,
+		user_id TEXT NOT NULL,
+		amount INTEGER NOT NULL,
+		FOREIGN KEY (item_id) REFERENCES expense_items(item_id),
+		FOREIGN KEY (user_id) REFERENCES users(user_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS balances (
+		group_id TEXT NOT NULL,
+		from_user_id TEXT NOT NULL,
+		to_user_id TEXT NOT NULL,
+		amount INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (group_id, from_user_id, to_user_id),
+		FOREIGN KEY (group_id) REFERENCES groups(group_id),
+		FOREIGN KEY (from_user_id) REFERENCES users(user_id),
+		FOREIGN KEY (to_user_id) REFERENCES users(user_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS settlements (
+		settlement_id TEXT PRIMARY KEY,
+		group_id TEXT NOT NULL,
+		from_user_id TEXT NOT NULL,
+		to_user_id TEXT NOT NULL,
+		amount INTEGER NOT NULL,
+		currency TEXT NOT NULL DEFAULT 'USD',
+		note TEXT NOT NULL DEFAULT '',
+		method TEXT NOT NULL DEFAULT '',
+		mode TEXT NOT NULL DEFAULT 'partial',
+		status TEXT NOT NULL DEFAULT 'confirmed',
+		recorded_by TEXT NOT NULL DEFAULT '',
+		batch_id TEXT NOT NULL DEFAULT '',
+		kind TEXT NOT NULL DEFAULT 'payment',
+		dispute_reason TEXT NOT NULL DEFAULT '',
+		date_created DATETIME DEFAULT CURRENT_TIMESTAMP,
+		confirmed_at DATETIME,
+		FOREIGN KEY (group_id) REFERENCES groups(group_id),
+		FOREIGN KEY (from_user_id) REFERENCES users(user_id),
+		FOREIGN KEY (to_user_id) REFERENCES users(user_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS expense_attachments (
+		attachment_id TEXT PRIMARY KEY,
+		expense_id TEXT NOT NULL,
+		file_name TEXT NOT NULL,
+		content_type TEXT NOT NULL,
+		size_bytes INTEGER NOT NULL,
+		blob_key TEXT NOT NULL,
+		thumbnail_key TEXT NOT NULL DEFAULT '',
+		uploaded_by TEXT NOT NULL,
+		date_created DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (expense_id) REFERENCES expenses(expense_id),
+		FOREIGN KEY (uploaded_by) REFERENCES users(user_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS ledger_entries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		group_id TEXT NOT NULL,
+		from_user_id TEXT NOT NULL,
+		to_user_id TEXT NOT NULL,
+		amount INTEGER NOT NULL,
+		entry_type TEXT NOT NULL,
+		reference_id TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (group_id) REFERENCES groups(group_id),
+		FOREIGN KEY (from_user_id) REFERENCES users(user_id),
+		FOREIGN KEY (to_user_id) REFERENCES users(user_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS exchange_rates (
+		from_currency TEXT NOT NULL,
+		to_currency TEXT NOT NULL,
+		rate_date TEXT NOT NULL,
+		rate REAL NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (from_currency, to_currency, rate_date)
+	)`,
+	`CREATE TABLE IF NOT EXISTS group_categories (
+		group_id TEXT NOT NULL,
+		name TEXT NOT NULL COLLATE NOCASE,
+		date_created DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (group_id, name),
+		FOREIGN KEY (group_id) REFERENCES groups(group_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS category_rules (
+		group_id TEXT NOT NULL DEFAULT '',
+		keyword TEXT NOT NULL,
+		category TEXT NOT NULL,
+		PRIMARY KEY (group_id, keyword)
+	)`,
+	`CREATE TABLE IF NOT EXISTS recurring_expenses (
+		template_id TEXT PRIMARY KEY,
+		group_id TEXT NOT NULL,
+		created_by TEXT NOT NULL,
+		expense_request TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		frequency TEXT NOT NULL,
+		interval_count INTEGER NOT NULL DEFAULT 1,
+		start_date TEXT NOT NULL,
+		end_date TEXT NOT NULL DEFAULT '',
+		max_occurrences INTEGER NOT NULL DEFAULT 0,
+		occurrences INTEGER NOT NULL DEFAULT 0,
+		next_date TEXT NOT NULL DEFAULT '',
+		paused INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		date_created DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (group_id) REFERENCES groups(group_id),
+		FOREIGN KEY (created_by) REFERENCES users(user_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS sessions (
+		token TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		user_name TEXT NOT NULL DEFAULT '',
+		expires_at DATETIME NOT NULL,
+		FOREIGN KEY (user_id) REFERENCES users(user_id)
+	)`,
+}
+
 func createTables() error {
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS users (
-			user_id TEXT PRIMARY KEY,
-			user_name TEXT NOT NULL,
-			user_email TEXT UNIQUE NOT NULL,
-			password_hash TEXT NOT NULL,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)`,
-		`CREATE TABLE IF NOT EXISTS groups (
-			group_id TEXT PRIMARY KEY,
-			group_name TEXT NOT NULL,
-			created_by TEXT NOT NULL,
-			date_created DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (created_by) REFERENCES users(user_id)
-		)`,
-		`CREATE TABLE IF NOT EXISTS group_members (
-			group_id TEXT NOT NULL,
-			user_id TEXT NOT NULL,
-			PRIMARY KEY (group_id, user_id),
-			FOREIGN KEY (group_id) REFERENCES groups(group_id),
-			FOREIGN KEY (user_id) REFERENCES users(user_id)
-		)`,
-		`CREATE TABLE IF NOT EXISTS expenses (
-			expense_id TEXT PRIMARY KEY,
-			expense_description TEXT NOT NULL,
-			expense_amount REAL NOT NULL,
-			group_id TEXT NOT NULL,
-			paid_by_user_id TEXT NOT NULL,
-			date_created DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (group_id) REFERENCES groups(group_id),
-			FOREIGN KEY (paid_by_user_id) REFERENCES users(user_id)
-		)`,
-		`CREATE TABLE IF NOT EXISTS splits (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			expense_id TEXT NOT NULL,
-			user_id TEXT NOT NULL,
-			amount REAL NOT NULL,
-			FOREIGN KEY (expense_id) REFERENCES expenses(expense_id),
-			FOREIGN KEY (user_id) REFERENCES users(user_id)
-		)`,
-		`CREATE TABLE IF NOT EXISTS balances (
-			group_id TEXT NOT NULL,
-			from_user_id TEXT NOT NULL,
-			to_user_id TEXT NOT NULL,
-			amount REAL NOT NULL DEFAULT 0,
-			PRIMARY KEY (group_id, from_user_id, to_user_id),
-			FOREIGN KEY (group_id) REFERENCES groups(group_id),
-			FOREIGN KEY (from_user_id) REFERENCES users(user_id),
-			FOREIGN KEY (to_user_id) REFERENCES users(user_id)
-		)`,
-		`CREATE TABLE IF NOT EXISTS sessions (
-			token TEXT PRIMARY KEY,
-			user_id TEXT NOT NULL,
-			user_name TEXT NOT NULL DEFAULT '',
-			expires_at DATETIME NOT NULL,
-			FOREIGN KEY (user_id) REFERENCES users(user_id)
-		)`,
+	for _, query := range schema {
+		if _, err := DB.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func migrate() error {
+	if err := migrateMoneyColumns(); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing("expenses", "deleted_at", "DATETIME"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing("groups", "simplify_debts", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing("groups", "base_currency", "TEXT NOT NULL DEFAULT 'USD'"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing("expenses", "exchange_rate", "REAL NOT NULL DEFAULT 1"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing("expenses", "category", "TEXT NOT NULL DEFAULT '"+DefaultCategory+"'"); err != nil {
+		return err
+	}
+	if err := seedCategoryRules(); err != nil {
+		return err
+	}
+	if err := migrateExpenseDates(); err != nil {
+		return err
+	}
+	if err := migrateDatedExchangeRates(); err != nil {
+		return err
+	}
+	if err := migrateSettlementStatus(); err != nil {
+		return err
+	}
+	if err := migrateExpensePayers(); err != nil {
+		return err
+	}
+	return migrateLedger()
+}
+
+// migrateMoneyColumns rebuilds tables from databases that stored amounts as
+// REAL major units, converting every amount to INTEGER minor units
+func migrateMoneyColumns() error {
+	columnType, err := getColumnType("expenses", "expense_amount")
+	if err != nil || columnType != "REAL" {
+		return err
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Keep other tables' foreign keys pointing at the rebuilt tables
+	if _, err := tx.Exec("PRAGMA legacy_alter_table = ON"); err != nil {
+		return err
+	}
+
+	tables := []string{"expenses", "splits", "balances"}
+	for _, table := range tables {
+		if _, err := tx.Exec("ALTER TABLE " + table + " RENAME TO " + table + "_legacy"); err != nil {
+			return err
+		}
+	}
+	for _, query := range schema {
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	copies := []string{
+		`INSERT INTO expenses (expense_id, expense_description, expense_amount, group_id, paid_by_user_id, date_created)
+		SELECT expense_id, expense_description, CAST(ROUND(expense_amount * 100) AS INTEGER), group_id, paid_by_user_id, date_created
+		FROM expenses_legacy`,
+		`INSERT INTO splits (id, expense_id, user_id, amount)
+		SELECT id, expense_id, user_id, CAST(ROUND(amount * 100) AS INTEGER) FROM splits_legacy`,
+		`INSERT INTO balances (group_id, from_user_id, to_user_id, amount)
+		SELECT group_id, from_user_id, to_user_id, CAST(ROUND(amount * 100) AS INTEGER) FROM balances_legacy`,
+		// Float drift leftovers round to zero and carry no debt
+		"DELETE FROM balances WHERE amount = 0",
+	}
+	for _, query := range copies {
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	for _, table := range tables {
+		if _, err := tx.Exec("DROP TABLE " + table + "_legacy"); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("PRAGMA legacy_alter_table = OFF"); err != nil {
+		return err
 	}
 
+	log.Println("✅ Migrated amounts to integer minor units")
+	return tx.Commit()
+}
+
+// migrateExpenseDates adds expense_date, dating existing expenses on the day
+// they were entered
+func migrateExpenseDates() error {
+	if err := addColumnIfMissing("expenses", "expense_date", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	_, err := DB.Exec("UPDATE expenses SET expense_date = COALESCE(date(date_created), date('now')) WHERE expense_date = ''")
+	return err
+}
+
+// migrateExpensePayers gives expenses recorded before multiple payers were
+// supported a single payer row for their full amount
+func migrateExpensePayers() error {
+	_, err := DB.Exec(`
+		INSERT INTO expense_payers (expense_id, user_id, amount)
+		SELECT expense_id, paid_by_user_id, expense_amount FROM expenses
+		WHERE expense_id NOT IN (SELECT expense_id FROM expense_payers)
+	`)
+	return err
+}
+
+// migrateDatedExchangeRates rebuilds an exchange rate table that held one rate
+// per pair, keeping each rate as of the day it was last updated
+func migrateDatedExchangeRates() error {
+	columnType, err := getColumnType("exchange_rates", "rate_date")
+	if err != nil || columnType != "" {
+		return err
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	queries := []string{
+		"ALTER TABLE exchange_rates RENAME TO exchange_rates_legacy",
+	}
+	for _, query := range schema {
+		if strings.Contains(query, "exchange_rates (") {
+			queries = append(queries, query)
+		}
+	}
+	queries = append(queries,
+		`INSERT INTO exchange_rates (from_currency, to_currency, rate_date, rate, updated_at)
+		SELECT from_currency, to_currency, date(updated_at), rate, updated_at FROM exchange_rates_legacy`,
+		"DROP TABLE exchange_rates_legacy",
+	)
 	for _, query := range queries {
-		if _, err := DB.Exec(query); err != nil {
+		if _, err := tx.Exec(query); err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return tx.Commit()
+}
+
+// migrateSettlementStatus adds confirmation columns to settlements recorded
+// before payments needed confirming; those were applied to balances at once,
+// so they count as confirmed and recorded by the payer
+func migrateSettlementStatus() error {
+	columns := [][2]string{
+		{"mode", "TEXT NOT NULL DEFAULT 'partial'"},
+		{"status", "TEXT NOT NULL DEFAULT 'confirmed'"},
+		{"recorded_by", "TEXT NOT NULL DEFAULT ''"},
+		{"batch_id", "TEXT NOT NULL DEFAULT ''"},
+		{"kind", "TEXT NOT NULL DEFAULT 'payment'"},
+		{"dispute_reason", "TEXT NOT NULL DEFAULT ''"},
+		{"confirmed_at", "DATETIME"},
+	}
+	for _, column := range columns {
+		if err := addColumnIfMissing("settlements", column[0], column[1]); err != nil {
+			return err
+		}
+	}
+	_, err := DB.Exec("UPDATE settlements SET recorded_by = from_user_id, confirmed_at = date_created WHERE recorded_by = ''")
+	return err
+}
+
+// addColumnIfMissing adds a column to a table created before the column was
+// part of the schema
+func addColumnIfMissing(table, column, definition string) error {
+	columnType, err := getColumnType(table, column)
+	if err != nil || columnType != "" {
+		return err
+	}
+	_, err = DB.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
+	return err
+}
+
+// getColumnType returns the declared type of a column, or "" if it is missing
+func getColumnType(table, column string) (string, error) {
+	rows, err := DB.Query("PRAGMA table_info(" + table + ")")
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, declType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &declType, &notNull, &defaultValue, &pk); err != nil {
+			return "", err
+		}
+		if name == column {
+			return declType, nil
+		}
+	}
+	return "", rows.Err()
 }
 
 func Close() {
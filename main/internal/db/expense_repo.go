@@ -1,64 +1,184 @@
 package db
 
-import "splitwise/main/internal/entity"
+import (
+	"database/sql"
+	"errors"
+	"splitwise/main/internal/balancesheet"
+	"splitwise/main/internal/entity"
+	"time"
+)
+
+var (
+	ErrExpenseDeleted    = errors.New("expense is deleted")
+	ErrExpenseNotDeleted = errors.New("expense is not deleted")
+)
+
+// ExpenseFilter narrows the expenses listed for a group; empty fields match
+// everything. From and To are inclusive expense dates as YYYY-MM-DD.
+type ExpenseFilter struct {
+	Category string
+	From     string
+	To       string
+}
 
 type ExpenseRecord struct {
-	ExpenseID          string        `json:"expense_id"`
-	ExpenseDescription string        `json:"expense_description"`
-	ExpenseAmount      float64       `json:"expense_amount"`
-	GroupID            string        `json:"group_id"`
-	GroupName          string        `json:"group_name"`
-	PaidByUserID       string        `json:"paid_by_user_id"`
-	PaidByUserName     string        `json:"paid_by_user_name"`
-	Splits             []SplitRecord `json:"splits"`
+	ExpenseID          string `json:"expense_id"`
+	ExpenseDescription string `json:"expense_description"`
+	Category           string `json:"category"`
+	// ExpenseDate is the day the expense happened, as YYYY-MM-DD; DateCreated
+	// is when it was entered
+	ExpenseDate   string          `json:"expense_date"`
+	ExpenseAmount entity.Money    `json:"expense_amount"`
+	Currency      entity.Currency `json:"currency"`
+	// ExchangeRate converts the expense into the group's base currency; it is
+	// fixed when the expense is recorded
+	ExchangeRate   float64            `json:"exchange_rate"`
+	BaseAmount     entity.Money       `json:"base_amount"`
+	BaseCurrency   entity.Currency    `json:"base_currency"`
+	GroupID        string             `json:"group_id"`
+	GroupName      string             `json:"group_name"`
+	PaidByUserID   string             `json:"paid_by_user_id"`
+	PaidByUserName string             `json:"paid_by_user_name"`
+	Payers         []PayerRecord      `json:"payers"`
+	Splits         []SplitRecord      `json:"splits"`
+	Receipt        *ReceiptRecord     `json:"receipt,omitempty"`
+	Attachments    []AttachmentRecord `json:"attachments"`
+	DateCreated    time.Time          `json:"date_created"`
+	DeletedAt      *time.Time         `json:"deleted_at,omitempty"`
 }
 
 type SplitRecord struct {
-	UserID   string  `json:"user_id"`
-	UserName string  `json:"user_name"`
-	Amount   float64 `json:"amount"`
+	UserID   string       `json:"user_id"`
+	UserName string       `json:"user_name"`
+	Amount   entity.Money `json:"amount"`
+}
+
+// PayerRecord is one member's contribution towards an expense
+type PayerRecord struct {
+	UserID   string       `json:"user_id"`
+	UserName string       `json:"user_name"`
+	Amount   entity.Money `json:"amount"`
+}
+
+// CreateExpense stores an expense with its payers and splits and applies what
+// it owes to balances, all in one transaction. The first payer is recorded as
+// the expense's main payer. rate converts the expense's currency into the
+// group's base currency.
+func CreateExpense(expenseID, description, category string, date time.Time, amount entity.Money, rate float64, groupID string, payers []*entity.Payer, splits []*entity.Split) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertExpense(tx, expenseID, description, category, date, amount, rate, groupID, payers, splits); err != nil {
+		return err
+	}
+	if err := applyDebts(tx, expenseID, groupID, payers, splits); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func CreateExpense(expenseID, description string, amount float64, groupID, paidByUserID string, splits []*entity.Split) error {
+// CreateItemizedExpense is CreateExpense for an expense with receipt line items
+func CreateItemizedExpense(expenseID, description, category string, date time.Time, amount entity.Money, rate float64, groupID string, payers []*entity.Payer, splits []*entity.Split, receipt *ReceiptRecord) error {
 	tx, err := DB.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
+	if err := insertExpense(tx, expenseID, description, category, date, amount, rate, groupID, payers, splits); err != nil {
+		return err
+	}
+	if err := insertReceipt(tx, expenseID, receipt); err != nil {
+		return err
+	}
+	if err := applyDebts(tx, expenseID, groupID, payers, splits); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertExpense(tx *sql.Tx, expenseID, description, category string, date time.Time, amount entity.Money, rate float64, groupID string, payers []*entity.Payer, splits []*entity.Split) error {
 	// Insert expense
-	_, err = tx.Exec(
-		"INSERT INTO expenses (expense_id, expense_description, expense_amount, group_id, paid_by_user_id) VALUES (?, ?, ?, ?, ?)",
-		expenseID, description, amount, groupID, paidByUserID,
+	_, err := tx.Exec(
+		"INSERT INTO expenses (expense_id, expense_description, category, expense_date, expense_amount, currency, exchange_rate, group_id, paid_by_user_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		expenseID, description, category, date.Format(dateLayout), amount.Amount, amount.Currency, rate, groupID, payers[0].User.UserID,
 	)
 	if err != nil {
 		return err
 	}
 
+	return insertExpenseShares(tx, expenseID, payers, splits)
+}
+
+// insertExpenseShares stores who paid and who owes what for an expense
+func insertExpenseShares(tx *sql.Tx, expenseID string, payers []*entity.Payer, splits []*entity.Split) error {
+	// Insert payers
+	for _, payer := range payers {
+		_, err := tx.Exec(
+			"INSERT INTO expense_payers (expense_id, user_id, amount) VALUES (?, ?, ?)",
+			expenseID, payer.User.UserID, payer.Amount.Amount,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Insert splits
 	for _, split := range splits {
-		_, err = tx.Exec(
+		_, err := tx.Exec(
 			"INSERT INTO splits (expense_id, user_id, amount) VALUES (?, ?, ?)",
-			expenseID, split.User.UserID, split.Amount,
+			expenseID, split.User.UserID, split.Amount.Amount,
 		)
 		if err != nil {
 			return err
 		}
 	}
 
-	return tx.Commit()
+	return nil
+}
+
+// GetGroupExpenses returns a group's expenses matching filter, leaving out
+// deleted ones, latest expense date first
+func GetGroupExpenses(groupID string, filter ExpenseFilter) ([]ExpenseRecord, error) {
+	condition := "e.group_id = ? AND e.deleted_at IS NULL"
+	args := []interface{}{groupID}
+	if filter.Category != "" {
+		condition += " AND e.category = ? COLLATE NOCASE"
+		args = append(args, filter.Category)
+	}
+	if filter.From != "" {
+		condition += " AND e.expense_date >= ?"
+		args = append(args, filter.From)
+	}
+	if filter.To != "" {
+		condition += " AND e.expense_date <= ?"
+		args = append(args, filter.To)
+	}
+	return listExpenses(condition, "e.expense_date DESC, e.date_created DESC", args...)
+}
+
+// GetDeletedGroupExpenses returns a group's deleted expenses, most recently
+// deleted first
+func GetDeletedGroupExpenses(groupID string) ([]ExpenseRecord, error) {
+	return listExpenses("e.group_id = ? AND e.deleted_at IS NOT NULL", "e.deleted_at DESC", groupID)
 }
 
-func GetGroupExpenses(groupID string) ([]ExpenseRecord, error) {
+// listExpenses returns the expenses matching condition with their payers,
+// splits, receipts and attachments
+func listExpenses(condition, orderBy string, args ...interface{}) ([]ExpenseRecord, error) {
 	rows, err := DB.Query(`
-		SELECT e.expense_id, e.expense_description, e.expense_amount, 
-			   e.group_id, g.group_name, e.paid_by_user_id, u.user_name
+		SELECT e.expense_id, e.expense_description, e.category, e.expense_date, e.expense_amount, e.currency, e.exchange_rate, g.base_currency,
+			   e.group_id, g.group_name, e.paid_by_user_id, u.user_name, e.date_created, e.deleted_at
 		FROM expenses e
 		JOIN groups g ON e.group_id = g.group_id
 		JOIN users u ON e.paid_by_user_id = u.user_id
-		WHERE e.group_id = ?
-		ORDER BY e.date_created DESC
-	`, groupID)
+		WHERE `+condition+`
+		ORDER BY `+orderBy, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -67,24 +187,227 @@ func GetGroupExpenses(groupID string) ([]ExpenseRecord, error) {
 	expenses := make([]ExpenseRecord, 0)
 	for rows.Next() {
 		exp := ExpenseRecord{}
+		var deletedAt sql.NullTime
 		if err := rows.Scan(
-			&exp.ExpenseID, &exp.ExpenseDescription, &exp.ExpenseAmount,
-			&exp.GroupID, &exp.GroupName, &exp.PaidByUserID, &exp.PaidByUserName,
+			&exp.ExpenseID, &exp.ExpenseDescription, &exp.Category, &exp.ExpenseDate, &exp.ExpenseAmount.Amount, &exp.Currency, &exp.ExchangeRate, &exp.BaseCurrency,
+			&exp.GroupID, &exp.GroupName, &exp.PaidByUserID, &exp.PaidByUserName, &exp.DateCreated, &deletedAt,
 		); err != nil {
 			return nil, err
 		}
-		// Fetch splits for this expense
-		exp.Splits, _ = GetExpenseSplits(exp.ExpenseID)
+		exp.ExpenseAmount.Currency = exp.Currency
+		exp.BaseAmount = exp.ExpenseAmount.Convert(exp.ExchangeRate, exp.BaseCurrency)
+		if deletedAt.Valid {
+			exp.DeletedAt = &deletedAt.Time
+		}
 		expenses = append(expenses, exp)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	// Fetch payers, splits and any receipt items for each expense
+	for i := range expenses {
+		exp := &expenses[i]
+		exp.Payers, _ = GetExpensePayers(exp.ExpenseID)
+		exp.Splits, _ = GetExpenseSplits(exp.ExpenseID)
+		exp.Receipt, _ = GetExpenseReceipt(exp.ExpenseID, exp.ExpenseAmount.Currency)
+		exp.Attachments, _ = GetExpenseAttachments(exp.ExpenseID)
+	}
 	return expenses, nil
 }
 
+// GetExpenseByID returns a single expense, deleted or not, with its payers,
+// splits, receipt and attachments
+func GetExpenseByID(expenseID string) (*ExpenseRecord, error) {
+	expenses, err := listExpenses("e.expense_id = ?", "e.date_created", expenseID)
+	if err != nil {
+		return nil, err
+	}
+	if len(expenses) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &expenses[0], nil
+}
+
+// ExpenseExists reports whether an expense with this ID was ever stored,
+// including one that has since been deleted
+func ExpenseExists(expenseID string) (bool, error) {
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM expenses WHERE expense_id = ?", expenseID).Scan(&count)
+	return count > 0, err
+}
+
+// UpdateExpense replaces an expense's description, category, date, amount,
+// exchange rate, payers, splits and receipt. The balance effect of the old payers and
+// splits is reversed and the new one applied in the same transaction, so
+// balances never see a half edited expense.
+func UpdateExpense(expenseID, description, category string, date time.Time, amount entity.Money, rate float64, payers []*entity.Payer, splits []*entity.Split, receipt *ReceiptRecord) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Reverse what the expense currently contributes to balances
+	groupID, err := applyExpenseBalances(tx, expenseID, true)
+	if err != nil {
+		return err
+	}
+
+	// Replace the expense's details, payers, splits and receipt
+	if err := deleteReceipts(tx, "expense_id = ?", expenseID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM expense_payers WHERE expense_id = ?", expenseID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM splits WHERE expense_id = ?", expenseID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		"UPDATE expenses SET expense_description = ?, category = ?, expense_date = ?, expense_amount = ?, currency = ?, exchange_rate = ?, paid_by_user_id = ? WHERE expense_id = ?",
+		description, category, date.Format(dateLayout), amount.Amount, amount.Currency, rate, payers[0].User.UserID, expenseID,
+	)
+	if err != nil {
+		return err
+	}
+	if err := insertExpenseShares(tx, expenseID, payers, splits); err != nil {
+		return err
+	}
+	if receipt != nil {
+		if err := insertReceipt(tx, expenseID, receipt); err != nil {
+			return err
+		}
+	}
+
+	// Apply the new contribution
+	if err := applyDebts(tx, expenseID, groupID, payers, splits); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// applyDebts records within tx what each member owes the payers of an expense
+func applyDebts(tx *sql.Tx, expenseID, groupID string, payers []*entity.Payer, splits []*entity.Split) error {
+	debts, err := baseCurrencyDebts(tx, expenseID, payers, splits)
+	if err != nil {
+		return err
+	}
+	for _, debt := range debts {
+		if err := applyBalance(tx, expenseID, groupID, debt.To.UserID, debt.From.UserID, debt.Amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// baseCurrencyDebts works out what each member owes the payers of an expense,
+// converted into the group's base currency at the expense's stored rate.
+// Converting the same shares always gives the same amounts, so reversing an
+// expense takes back exactly what applying it added.
+func baseCurrencyDebts(tx *sql.Tx, expenseID string, payers []*entity.Payer, splits []*entity.Split) ([]*balancesheet.Debt, error) {
+	var rate float64
+	var baseCurrency entity.Currency
+	err := tx.QueryRow(`
+		SELECT e.exchange_rate, g.base_currency
+		FROM expenses e
+		JOIN groups g ON e.group_id = g.group_id
+		WHERE e.expense_id = ?
+	`, expenseID).Scan(&rate, &baseCurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	debts := balancesheet.ComputeDebts(payers, splits)
+	for _, debt := range debts {
+		debt.Amount = debt.Amount.Convert(rate, baseCurrency)
+	}
+	return debts, nil
+}
+
+// applyExpenseBalances adds a live expense's effect to its group's balances
+// within tx, or takes it back out when reverse is set. It returns the group
+// the expense belongs to and fails with ErrExpenseDeleted for deleted ones.
+func applyExpenseBalances(tx *sql.Tx, expenseID string, reverse bool) (string, error) {
+	var groupID string
+	var deletedAt sql.NullTime
+	err := tx.QueryRow("SELECT group_id, deleted_at FROM expenses WHERE expense_id = ?", expenseID).Scan(&groupID, &deletedAt)
+	if err != nil {
+		return "", err
+	}
+	if deletedAt.Valid {
+		return "", ErrExpenseDeleted
+	}
+
+	payers, splits, err := loadExpenseShares(tx, expenseID)
+	if err != nil {
+		return "", err
+	}
+	debts, err := baseCurrencyDebts(tx, expenseID, payers, splits)
+	if err != nil {
+		return "", err
+	}
+	for _, debt := range debts {
+		amount := debt.Amount
+		if reverse {
+			amount = amount.Neg()
+		}
+		if err := applyBalance(tx, expenseID, groupID, debt.To.UserID, debt.From.UserID, amount); err != nil {
+			return "", err
+		}
+	}
+	return groupID, nil
+}
+
+// loadExpenseShares reads an expense's payers and splits within tx in the
+// shape the balance sheet works with
+func loadExpenseShares(tx *sql.Tx, expenseID string) ([]*entity.Payer, []*entity.Split, error) {
+	var currency entity.Currency
+	if err := tx.QueryRow("SELECT currency FROM expenses WHERE expense_id = ?", expenseID).Scan(&currency); err != nil {
+		return nil, nil, err
+	}
+
+	payers := make([]*entity.Payer, 0)
+	rows, err := tx.Query("SELECT user_id, amount FROM expense_payers WHERE expense_id = ? ORDER BY amount DESC", expenseID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for rows.Next() {
+		var userID string
+		var amount int64
+		if err := rows.Scan(&userID, &amount); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		payers = append(payers, entity.NewPayer(&entity.User{UserID: userID}, entity.NewMoney(amount, currency)))
+	}
+	rows.Close()
+
+	splits := make([]*entity.Split, 0)
+	rows, err = tx.Query("SELECT user_id, amount FROM splits WHERE expense_id = ?", expenseID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID string
+		var amount int64
+		if err := rows.Scan(&userID, &amount); err != nil {
+			return nil, nil, err
+		}
+		splits = append(splits, entity.NewSplit(&entity.User{UserID: userID}, entity.NewMoney(amount, currency)))
+	}
+	return payers, splits, rows.Err()
+}
+
 func GetExpenseSplits(expenseID string) ([]SplitRecord, error) {
 	rows, err := DB.Query(`
-		SELECT s.user_id, u.user_name, s.amount
+		SELECT s.user_id, u.user_name, s.amount, e.currency
 		FROM splits s
 		JOIN users u ON s.user_id = u.user_id
+		JOIN expenses e ON s.expense_id = e.expense_id
 		WHERE s.expense_id = ?
 	`, expenseID)
 	if err != nil {
@@ -95,7 +418,7 @@ func GetExpenseSplits(expenseID string) ([]SplitRecord, error) {
 	splits := make([]SplitRecord, 0)
 	for rows.Next() {
 		split := SplitRecord{}
-		if err := rows.Scan(&split.UserID, &split.UserName, &split.Amount); err != nil {
+		if err := rows.Scan(&split.UserID, &split.UserName, &split.Amount.Amount, &split.Amount.Currency); err != nil {
 			return nil, err
 		}
 		splits = append(splits, split)
@@ -103,13 +426,39 @@ func GetExpenseSplits(expenseID string) ([]SplitRecord, error) {
 	return splits, nil
 }
 
+func GetExpensePayers(expenseID string) ([]PayerRecord, error) {
+	rows, err := DB.Query(`
+		SELECT p.user_id, u.user_name, p.amount, e.currency
+		FROM expense_payers p
+		JOIN users u ON p.user_id = u.user_id
+		JOIN expenses e ON p.expense_id = e.expense_id
+		WHERE p.expense_id = ?
+		ORDER BY p.amount DESC
+	`, expenseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payers := make([]PayerRecord, 0)
+	for rows.Next() {
+		payer := PayerRecord{}
+		if err := rows.Scan(&payer.UserID, &payer.UserName, &payer.Amount.Amount, &payer.Amount.Currency); err != nil {
+			return nil, err
+		}
+		payers = append(payers, payer)
+	}
+	return payers, nil
+}
+
 func GetAllExpenses() ([]ExpenseRecord, error) {
 	rows, err := DB.Query(`
-		SELECT e.expense_id, e.expense_description, e.expense_amount, 
+		SELECT e.expense_id, e.expense_description, e.category, e.expense_amount, e.currency,
 			   e.group_id, g.group_name, e.paid_by_user_id, u.user_name
 		FROM expenses e
 		JOIN groups g ON e.group_id = g.group_id
 		JOIN users u ON e.paid_by_user_id = u.user_id
+		WHERE e.deleted_at IS NULL
 		ORDER BY e.date_created DESC
 	`)
 	if err != nil {
@@ -121,16 +470,19 @@ func GetAllExpenses() ([]ExpenseRecord, error) {
 	for rows.Next() {
 		exp := ExpenseRecord{}
 		if err := rows.Scan(
-			&exp.ExpenseID, &exp.ExpenseDescription, &exp.ExpenseAmount,
+			&exp.ExpenseID, &exp.ExpenseDescription, &exp.Category, &exp.ExpenseAmount.Amount, &exp.Currency,
 			&exp.GroupID, &exp.GroupName, &exp.PaidByUserID, &exp.PaidByUserName,
 		); err != nil {
 			return nil, err
 		}
+		exp.ExpenseAmount.Currency = exp.Currency
 		expenses = append(expenses, exp)
 	}
 	return expenses, nil
 }
 
+// DeleteExpense marks an expense as deleted and reverses its effect on
+// balances in the same transaction. Its rows are kept so it can be restored.
 func DeleteExpense(expenseID string) error {
 	tx, err := DB.Begin()
 	if err != nil {
@@ -138,17 +490,38 @@ func DeleteExpense(expenseID string) error {
 	}
 	defer tx.Rollback()
 
-	// Delete splits first
-	_, err = tx.Exec("DELETE FROM splits WHERE expense_id = ?", expenseID)
+	if _, err := applyExpenseBalances(tx, expenseID, true); err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE expenses SET deleted_at = CURRENT_TIMESTAMP WHERE expense_id = ?", expenseID)
 	if err != nil {
 		return err
 	}
 
-	// Delete expense
-	_, err = tx.Exec("DELETE FROM expenses WHERE expense_id = ?", expenseID)
+	return tx.Commit()
+}
+
+// RestoreExpense brings back a deleted expense and re-applies its effect on
+// balances in the same transaction
+func RestoreExpense(expenseID string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE expenses SET deleted_at = NULL WHERE expense_id = ? AND deleted_at IS NOT NULL", expenseID)
 	if err != nil {
 		return err
 	}
+	if restored, err := result.RowsAffected(); err != nil {
+		return err
+	} else if restored == 0 {
+		return ErrExpenseNotDeleted
+	}
+	if _, err := applyExpenseBalances(tx, expenseID, false); err != nil {
+		return err
+	}
 
 	return tx.Commit()
 }
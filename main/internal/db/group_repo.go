@@ -6,9 +6,9 @@ import (
 
 type GroupWithBalance struct {
 	*entity.Group
-	YouOwe      float64 `json:"you_owe"`
-	YouAreOwed  float64 `json:"you_are_owed"`
-	HasPending  bool    `json:"has_pending"`
+	YouOwe     entity.Money `json:"you_owe"`
+	YouAreOwed entity.Money `json:"you_are_owed"`
+	HasPending bool         `json:"has_pending"`
 }
 
 func CreateGroup(group *entity.Group, createdBy string) error {
@@ -19,9 +19,12 @@ func CreateGroup(group *entity.Group, createdBy string) error {
 	defer tx.Rollback()
 
 	// Insert group
+	if group.BaseCurrency == "" {
+		group.BaseCurrency = entity.DefaultCurrency
+	}
 	_, err = tx.Exec(
-		"INSERT INTO groups (group_id, group_name, created_by) VALUES (?, ?, ?)",
-		group.GroupID, group.GroupName, createdBy,
+		"INSERT INTO groups (group_id, group_name, created_by, base_currency) VALUES (?, ?, ?, ?)",
+		group.GroupID, group.GroupName, createdBy, group.BaseCurrency,
 	)
 	if err != nil {
 		return err
@@ -43,7 +46,7 @@ func CreateGroup(group *entity.Group, createdBy string) error {
 
 func GetUserGroups(userID string) ([]*entity.Group, error) {
 	rows, err := DB.Query(`
-		SELECT g.group_id, g.group_name, g.date_created 
+		SELECT g.group_id, g.group_name, g.date_created, g.simplify_debts, g.base_currency
 		FROM groups g
 		JOIN group_members gm ON g.group_id = gm.group_id
 		WHERE gm.user_id = ?
@@ -57,7 +60,7 @@ func GetUserGroups(userID string) ([]*entity.Group, error) {
 	groups := make([]*entity.Group, 0)
 	for rows.Next() {
 		group := &entity.Group{}
-		if err := rows.Scan(&group.GroupID, &group.GroupName, &group.DateCreated); err != nil {
+		if err := rows.Scan(&group.GroupID, &group.GroupName, &group.DateCreated, &group.SimplifyDebts, &group.BaseCurrency); err != nil {
 			return nil, err
 		}
 		group.GroupMembers, _ = GetGroupMembers(group.GroupID)
@@ -68,7 +71,7 @@ func GetUserGroups(userID string) ([]*entity.Group, error) {
 
 func GetUserGroupsWithBalances(userID string) ([]GroupWithBalance, error) {
 	rows, err := DB.Query(`
-		SELECT g.group_id, g.group_name, g.date_created 
+		SELECT g.group_id, g.group_name, g.date_created, g.simplify_debts, g.base_currency
 		FROM groups g
 		JOIN group_members gm ON g.group_id = gm.group_id
 		WHERE gm.user_id = ?
@@ -82,44 +85,49 @@ func GetUserGroupsWithBalances(userID string) ([]GroupWithBalance, error) {
 	groups := make([]GroupWithBalance, 0)
 	for rows.Next() {
 		group := &entity.Group{}
-		if err := rows.Scan(&group.GroupID, &group.GroupName, &group.DateCreated); err != nil {
+		if err := rows.Scan(&group.GroupID, &group.GroupName, &group.DateCreated, &group.SimplifyDebts, &group.BaseCurrency); err != nil {
 			return nil, err
 		}
 		group.GroupMembers, _ = GetGroupMembers(group.GroupID)
-		
+
 		// Get balance for this user in this group
-		youOwe, youAreOwed := GetUserGroupBalance(userID, group.GroupID)
-		
+		youOwe, youAreOwed := GetUserGroupBalance(userID, group.GroupID, group.BaseCurrency)
+
 		groups = append(groups, GroupWithBalance{
 			Group:      group,
 			YouOwe:     youOwe,
 			YouAreOwed: youAreOwed,
-			HasPending: youOwe > 0 || youAreOwed > 0,
+			HasPending: youOwe.IsPositive() || youAreOwed.IsPositive(),
 		})
 	}
 	return groups, nil
 }
 
-func GetUserGroupBalance(userID, groupID string) (youOwe float64, youAreOwed float64) {
+// GetUserGroupBalance totals what the user owes and is owed in a group, in the
+// group's base currency
+func GetUserGroupBalance(userID, groupID string, currency entity.Currency) (youOwe entity.Money, youAreOwed entity.Money) {
+	youOwe = entity.NewMoney(0, currency)
+	youAreOwed = entity.NewMoney(0, currency)
+
 	// What you owe in this group
 	DB.QueryRow(`
 		SELECT COALESCE(SUM(amount), 0) 
 		FROM balances 
-		WHERE group_id = ? AND from_user_id = ? AND amount > 0.10
-	`, groupID, userID).Scan(&youOwe)
+		WHERE group_id = ? AND from_user_id = ? AND amount > 0
+	`, groupID, userID).Scan(&youOwe.Amount)
 
 	// What you're owed in this group
 	DB.QueryRow(`
 		SELECT COALESCE(SUM(amount), 0) 
 		FROM balances 
-		WHERE group_id = ? AND to_user_id = ? AND amount > 0.10
-	`, groupID, userID).Scan(&youAreOwed)
+		WHERE group_id = ? AND to_user_id = ? AND amount > 0
+	`, groupID, userID).Scan(&youAreOwed.Amount)
 
 	return
 }
 
 func GetAllGroups() ([]*entity.Group, error) {
-	rows, err := DB.Query("SELECT group_id, group_name, date_created FROM groups")
+	rows, err := DB.Query("SELECT group_id, group_name, date_created, simplify_debts, base_currency FROM groups")
 	if err != nil {
 		return nil, err
 	}
@@ -128,7 +136,7 @@ func GetAllGroups() ([]*entity.Group, error) {
 	groups := make([]*entity.Group, 0)
 	for rows.Next() {
 		group := &entity.Group{}
-		if err := rows.Scan(&group.GroupID, &group.GroupName, &group.DateCreated); err != nil {
+		if err := rows.Scan(&group.GroupID, &group.GroupName, &group.DateCreated, &group.SimplifyDebts, &group.BaseCurrency); err != nil {
 			return nil, err
 		}
 		group.GroupMembers, _ = GetGroupMembers(group.GroupID)
@@ -140,9 +148,9 @@ func GetAllGroups() ([]*entity.Group, error) {
 func GetGroupByID(groupID string) (*entity.Group, error) {
 	group := &entity.Group{}
 	err := DB.QueryRow(
-		"SELECT group_id, group_name, date_created FROM groups WHERE group_id = ?",
+		"SELECT group_id, group_name, date_created, simplify_debts, base_currency FROM groups WHERE group_id = ?",
 		groupID,
-	).Scan(&group.GroupID, &group.GroupName, &group.DateCreated)
+	).Scan(&group.GroupID, &group.GroupName, &group.DateCreated, &group.SimplifyDebts, &group.BaseCurrency)
 	if err != nil {
 		return nil, err
 	}
@@ -150,6 +158,12 @@ func GetGroupByID(groupID string) (*entity.Group, error) {
 	return group, nil
 }
 
+// SetGroupSimplifyDebts sets whether the group shows simplified debts by default
+func SetGroupSimplifyDebts(groupID string, enabled bool) error {
+	_, err := DB.Exec("UPDATE groups SET simplify_debts = ? WHERE group_id = ?", enabled, groupID)
+	return err
+}
+
 func GetGroupMembers(groupID string) ([]*entity.User, error) {
 	rows, err := DB.Query(`
 		SELECT u.user_id, u.user_name, u.user_email 
@@ -0,0 +1,235 @@
+package db
+
+import (
+	"database/sql"
+	"log"
+	"sort"
+	"splitwise/main/internal/entity"
+)
+
+// Kinds of ledger entry
+const (
+	LedgerExpense    = "expense"
+	LedgerSettlement = "settlement"
+	// LedgerOffset cancels debts against each other in a cross-group settlement
+	LedgerOffset = "offset"
+	// LedgerOpening carries over balances recorded before the ledger existed
+	LedgerOpening = "opening"
+)
+
+// LedgerEntry is one append-only change to what FromUserID owes ToUserID in a
+// group. Reversals and settlements are recorded as negative amounts.
+type LedgerEntry struct {
+	GroupID     string       `json:"group_id"`
+	FromUserID  string       `json:"from_user_id"`
+	ToUserID    string       `json:"to_user_id"`
+	Amount      entity.Money `json:"amount"`
+	EntryType   string       `json:"entry_type"`
+	ReferenceID string       `json:"reference_id"`
+}
+
+// BalanceDrift is a pair whose stored balance disagrees with the ledger
+type BalanceDrift struct {
+	GroupID    string `json:"group_id"`
+	FromUserID string `json:"from_user_id"`
+	ToUserID   string `json:"to_user_id"`
+	// Currency is the group's base currency, which balances are kept in
+	Currency entity.Currency `json:"currency"`
+	Stored   entity.Money    `json:"stored"`
+	Expected entity.Money    `json:"expected"`
+}
+
+type balanceKey struct {
+	groupID, fromUserID, toUserID string
+}
+
+// appendLedgerEntry records entry in the ledger and applies it to the
+// balances table within tx
+func appendLedgerEntry(tx *sql.Tx, entry LedgerEntry) error {
+	if entry.FromUserID == entry.ToUserID || entry.Amount.IsZero() {
+		return nil
+	}
+
+	_, err := tx.Exec(
+		"INSERT INTO ledger_entries (group_id, from_user_id, to_user_id, amount, entry_type, reference_id) VALUES (?, ?, ?, ?, ?, ?)",
+		entry.GroupID, entry.FromUserID, entry.ToUserID, entry.Amount.Amount, entry.EntryType, entry.ReferenceID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return adjustBalance(tx, entry.GroupID, entry.FromUserID, entry.ToUserID, entry.Amount)
+}
+
+// ledgerBalances sums the ledger into the symmetric rows the balances table
+// holds, leaving out pairs that net to zero
+func ledgerBalances() (map[balanceKey]int64, error) {
+	rows, err := DB.Query(`
+		SELECT group_id, from_user_id, to_user_id, SUM(amount) FROM (
+			SELECT group_id, from_user_id, to_user_id, amount FROM ledger_entries
+			UNION ALL
+			SELECT group_id, to_user_id, from_user_id, -amount FROM ledger_entries
+		)
+		GROUP BY group_id, from_user_id, to_user_id
+		HAVING SUM(amount) != 0
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	balances := make(map[balanceKey]int64)
+	for rows.Next() {
+		var key balanceKey
+		var amount int64
+		if err := rows.Scan(&key.groupID, &key.fromUserID, &key.toUserID, &amount); err != nil {
+			return nil, err
+		}
+		balances[key] = amount
+	}
+	return balances, rows.Err()
+}
+
+// CheckBalanceDrift compares the balances table against the ledger and
+// returns every pair where they disagree
+func CheckBalanceDrift() ([]BalanceDrift, error) {
+	expected, err := ledgerBalances()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query("SELECT group_id, from_user_id, to_user_id, amount FROM balances WHERE amount != 0")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stored := make(map[balanceKey]int64)
+	for rows.Next() {
+		var key balanceKey
+		var amount int64
+		if err := rows.Scan(&key.groupID, &key.fromUserID, &key.toUserID, &amount); err != nil {
+			return nil, err
+		}
+		stored[key] = amount
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	currencies, err := groupBaseCurrencies()
+	if err != nil {
+		return nil, err
+	}
+
+	drift := make([]BalanceDrift, 0)
+	addDrift := func(key balanceKey) {
+		currency, ok := currencies[key.groupID]
+		if !ok {
+			currency = entity.DefaultCurrency
+		}
+		drift = append(drift, BalanceDrift{
+			GroupID:    key.groupID,
+			FromUserID: key.fromUserID,
+			ToUserID:   key.toUserID,
+			Currency:   currency,
+			Stored:     entity.NewMoney(stored[key], currency),
+			Expected:   entity.NewMoney(expected[key], currency),
+		})
+	}
+	for key, amount := range stored {
+		if expected[key] != amount {
+			addDrift(key)
+		}
+	}
+	for key := range expected {
+		if _, ok := stored[key]; !ok {
+			addDrift(key)
+		}
+	}
+
+	sort.Slice(drift, func(i, j int) bool {
+		a, b := drift[i], drift[j]
+		if a.GroupID != b.GroupID {
+			return a.GroupID < b.GroupID
+		}
+		if a.FromUserID != b.FromUserID {
+			return a.FromUserID < b.FromUserID
+		}
+		return a.ToUserID < b.ToUserID
+	})
+	return drift, nil
+}
+
+// groupBaseCurrencies returns every group's base currency by group ID
+func groupBaseCurrencies() (map[string]entity.Currency, error) {
+	rows, err := DB.Query("SELECT group_id, base_currency FROM groups")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	currencies := make(map[string]entity.Currency)
+	for rows.Next() {
+		var groupID string
+		var currency entity.Currency
+		if err := rows.Scan(&groupID, &currency); err != nil {
+			return nil, err
+		}
+		currencies[groupID] = currency
+	}
+	return currencies, rows.Err()
+}
+
+// RebuildBalances throws away the balances table and recomputes it from the
+// ledger
+func RebuildBalances() error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM balances"); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO balances (group_id, from_user_id, to_user_id, amount)
+		SELECT group_id, from_user_id, to_user_id, SUM(amount) FROM (
+			SELECT group_id, from_user_id, to_user_id, amount FROM ledger_entries
+			UNION ALL
+			SELECT group_id, to_user_id, from_user_id, -amount FROM ledger_entries
+		)
+		GROUP BY group_id, from_user_id, to_user_id
+		HAVING SUM(amount) != 0
+	`)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// migrateLedger seeds an empty ledger with opening entries for the balances
+// recorded before the ledger existed, so the two start out in agreement
+func migrateLedger() error {
+	var entries int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM ledger_entries").Scan(&entries); err != nil {
+		return err
+	}
+	if entries > 0 {
+		return nil
+	}
+
+	result, err := DB.Exec(`
+		INSERT INTO ledger_entries (group_id, from_user_id, to_user_id, amount, entry_type, reference_id)
+		SELECT group_id, from_user_id, to_user_id, amount, ?, '' FROM balances WHERE amount > 0
+	`, LedgerOpening)
+	if err != nil {
+		return err
+	}
+	if seeded, _ := result.RowsAffected(); seeded > 0 {
+		log.Printf("✅ Seeded ledger with %d opening balances", seeded)
+	}
+	return nil
+}
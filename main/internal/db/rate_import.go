@@ -0,0 +1,155 @@
+package db
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"splitwise/main/internal/entity"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrNoRatesInFile = errors.New("file contains no exchange rates")
+
+// ecbDateLayouts are the date formats used across the ECB's rate downloads
+var ecbDateLayouts = []string{dateLayout, "2 January 2006", "02 January 2006"}
+
+// ecbEnvelope is the shape of the ECB's eurofxref XML feeds: a Cube per day
+// holding a Cube per currency
+type ecbEnvelope struct {
+	Days []struct {
+		Time  string `xml:"time,attr"`
+		Rates []struct {
+			Currency string `xml:"currency,attr"`
+			Rate     string `xml:"rate,attr"`
+		} `xml:"Cube"`
+	} `xml:"Cube>Cube"`
+}
+
+// ParseECBRates reads euro reference rates as published by the European
+// Central Bank, either the XML feeds (eurofxref-daily.xml, eurofxref-hist.xml)
+// or the CSV downloads (eurofxref.csv, eurofxref-hist.csv). Every rate is
+// from EUR; missing values such as "N/A" are skipped.
+func ParseECBRates(data []byte) ([]ExchangeRate, error) {
+	var rates []ExchangeRate
+	var err error
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("<")) {
+		rates, err = parseECBXML(trimmed)
+	} else {
+		rates, err = parseECBCSV(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rates) == 0 {
+		return nil, ErrNoRatesInFile
+	}
+	return rates, nil
+}
+
+func parseECBXML(data []byte) ([]ExchangeRate, error) {
+	var envelope ecbEnvelope
+	if err := xml.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("invalid XML: %w", err)
+	}
+
+	rates := make([]ExchangeRate, 0)
+	for _, day := range envelope.Days {
+		date, err := parseECBDate(day.Time)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range day.Rates {
+			rate, ok, err := parseECBRate(date, r.Currency, r.Rate)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				rates = append(rates, rate)
+			}
+		}
+	}
+	return rates, nil
+}
+
+func parseECBCSV(data []byte) ([]ExchangeRate, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	if len(header) == 0 || !strings.EqualFold(strings.TrimSpace(header[0]), "Date") {
+		return nil, errors.New("invalid CSV: first column must be Date")
+	}
+
+	rates := make([]ExchangeRate, 0)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		if len(record) == 0 || strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+
+		date, err := parseECBDate(record[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		for i := 1; i < len(record) && i < len(header); i++ {
+			rate, ok, err := parseECBRate(date, header[i], record[i])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			if ok {
+				rates = append(rates, rate)
+			}
+		}
+	}
+	return rates, nil
+}
+
+func parseECBDate(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range ecbDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date.Format(dateLayout), nil
+		}
+	}
+	return "", fmt.Errorf("unrecognised date %q", value)
+}
+
+// parseECBRate reads one currency's rate on date, reporting false for blank
+// columns and values the ECB marks as missing
+func parseECBRate(date, currencyCode, value string) (ExchangeRate, bool, error) {
+	currencyCode = strings.TrimSpace(currencyCode)
+	value = strings.TrimSpace(value)
+	if currencyCode == "" || value == "" || strings.EqualFold(value, "N/A") {
+		return ExchangeRate{}, false, nil
+	}
+
+	currency, err := entity.ParseCurrency(currencyCode)
+	if err != nil {
+		return ExchangeRate{}, false, fmt.Errorf("invalid currency %q", currencyCode)
+	}
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate <= 0 {
+		return ExchangeRate{}, false, fmt.Errorf("invalid rate %q for %s", value, currency)
+	}
+	return ExchangeRate{
+		FromCurrency: ReferenceCurrency,
+		ToCurrency:   currency,
+		RateDate:     date,
+		Rate:         rate,
+	}, true, nil
+}
@@ -0,0 +1,150 @@
+package db
+
+import (
+	"database/sql"
+	"splitwise/main/internal/entity"
+)
+
+// ReceiptRecord is the itemized breakdown behind an expense
+type ReceiptRecord struct {
+	Items []LineItemRecord `json:"items"`
+	Tax   entity.Money     `json:"tax"`
+	Tip   entity.Money     `json:"tip"`
+}
+
+type LineItemRecord struct {
+	ItemID    int64         `json:"item_id"`
+	Name      string        `json:"name"`
+	UnitPrice entity.Money  `json:"unit_price"`
+	Quantity  int64         `json:"quantity"`
+	SplitType string        `json:"split_type"`
+	Splits    []SplitRecord `json:"splits"`
+}
+
+func insertReceipt(tx *sql.Tx, expenseID string, receipt *ReceiptRecord) error {
+	_, err := tx.Exec(
+		"INSERT INTO expense_receipts (expense_id, tax_amount, tip_amount) VALUES (?, ?, ?)",
+		expenseID, receipt.Tax.Amount, receipt.Tip.Amount,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range receipt.Items {
+		result, err := tx.Exec(
+			"INSERT INTO expense_items (expense_id, item_name, unit_price, quantity, split_type) VALUES (?, ?, ?, ?, ?)",
+			expenseID, item.Name, item.UnitPrice.Amount, item.Quantity, item.SplitType,
+		)
+		if err != nil {
+			return err
+		}
+		itemID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		for _, split := range item.Splits {
+			_, err = tx.Exec(
+				"INSERT INTO expense_item_splits (item_id, user_id, amount) VALUES (?, ?, ?)",
+				itemID, split.UserID, split.Amount.Amount,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// GetExpenseReceipt returns the receipt for an itemized expense, or nil for a
+// plain one
+func GetExpenseReceipt(expenseID string, currency entity.Currency) (*ReceiptRecord, error) {
+	receipt := &ReceiptRecord{
+		Items: make([]LineItemRecord, 0),
+		Tax:   entity.NewMoney(0, currency),
+		Tip:   entity.NewMoney(0, currency),
+	}
+	err := DB.QueryRow(
+		"SELECT tax_amount, tip_amount FROM expense_receipts WHERE expense_id = ?",
+		expenseID,
+	).Scan(&receipt.Tax.Amount, &receipt.Tip.Amount)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query(`
+		SELECT item_id, item_name, unit_price, quantity, split_type
+		FROM expense_items
+		WHERE expense_id = ?
+		ORDER BY item_id
+	`, expenseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item := LineItemRecord{UnitPrice: entity.NewMoney(0, currency)}
+		if err := rows.Scan(&item.ItemID, &item.Name, &item.UnitPrice.Amount, &item.Quantity, &item.SplitType); err != nil {
+			return nil, err
+		}
+		receipt.Items = append(receipt.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range receipt.Items {
+		receipt.Items[i].Splits, err = getItemSplits(receipt.Items[i].ItemID, currency)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return receipt, nil
+}
+
+func getItemSplits(itemID int64, currency entity.Currency) ([]SplitRecord, error) {
+	rows, err := DB.Query(`
+		SELECT s.user_id, u.user_name, s.amount
+		FROM expense_item_splits s
+		JOIN users u ON s.user_id = u.user_id
+		WHERE s.item_id = ?
+	`, itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	splits := make([]SplitRecord, 0)
+	for rows.Next() {
+		split := SplitRecord{Amount: entity.NewMoney(0, currency)}
+		if err := rows.Scan(&split.UserID, &split.UserName, &split.Amount.Amount); err != nil {
+			return nil, err
+		}
+		splits = append(splits, split)
+	}
+	return splits, nil
+}
+
+// deleteReceipts removes receipt rows for the expenses matching where
+func deleteReceipts(tx *sql.Tx, where string, args ...interface{}) error {
+	_, err := tx.Exec(`
+		DELETE FROM expense_item_splits WHERE item_id IN
+		(SELECT item_id FROM expense_items WHERE `+where+`)
+	`, args...)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM expense_items WHERE "+where, args...)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM expense_receipts WHERE "+where, args...)
+	return err
+}
@@ -0,0 +1,193 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// How often a recurring expense repeats
+const (
+	RecurDaily   = "daily"
+	RecurWeekly  = "weekly"
+	RecurMonthly = "monthly"
+	RecurYearly  = "yearly"
+)
+
+// RecurringExpense is a template the scheduler turns into an expense on every
+// occurrence date. It stops after EndDate or MaxOccurrences, whichever comes
+// first, and NextDate is empty once it has.
+type RecurringExpense struct {
+	TemplateID string `json:"template_id"`
+	GroupID    string `json:"group_id"`
+	CreatedBy  string `json:"created_by"`
+	// Expense is the expense request each occurrence is created from
+	Expense        json.RawMessage `json:"expense"`
+	Description    string          `json:"expense_description"`
+	Frequency      string          `json:"frequency"`
+	Interval       int             `json:"interval"`
+	StartDate      string          `json:"start_date"`
+	EndDate        string          `json:"end_date,omitempty"`
+	MaxOccurrences int             `json:"max_occurrences,omitempty"`
+	Occurrences    int             `json:"occurrences"`
+	NextDate       string          `json:"next_date,omitempty"`
+	// Paused templates failed to create an expense and are skipped until
+	// resumed; LastError says why
+	Paused      bool      `json:"paused"`
+	LastError   string    `json:"last_error,omitempty"`
+	DateCreated time.Time `json:"date_created"`
+}
+
+// ValidFrequency reports whether frequency is one the scheduler understands
+func ValidFrequency(frequency string) bool {
+	switch frequency {
+	case RecurDaily, RecurWeekly, RecurMonthly, RecurYearly:
+		return true
+	}
+	return false
+}
+
+// NextOccurrence returns the date of occurrence n, counting from 0, or "" if
+// the schedule ends before it
+func (r *RecurringExpense) NextOccurrence(n int) string {
+	if r.MaxOccurrences > 0 && n >= r.MaxOccurrences {
+		return ""
+	}
+	start, err := time.Parse(dateLayout, r.StartDate)
+	if err != nil {
+		return ""
+	}
+
+	steps := r.Interval * n
+	var date time.Time
+	switch r.Frequency {
+	case RecurDaily:
+		date = start.AddDate(0, 0, steps)
+	case RecurWeekly:
+		date = start.AddDate(0, 0, 7*steps)
+	case RecurMonthly:
+		date = addMonths(start, steps)
+	case RecurYearly:
+		date = addMonths(start, 12*steps)
+	default:
+		return ""
+	}
+
+	next := date.Format(dateLayout)
+	if r.EndDate != "" && next > r.EndDate {
+		return ""
+	}
+	return next
+}
+
+// addMonths moves t by whole months, landing on the last day of months too
+// short for t's day, so a schedule on the 31st stays at the end of the month
+func addMonths(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, time.UTC)
+}
+
+// CreateRecurringExpense stores a new template, due first on its start date
+func CreateRecurringExpense(r *RecurringExpense) error {
+	r.NextDate = r.NextOccurrence(0)
+	_, err := DB.Exec(`
+		INSERT INTO recurring_expenses (template_id, group_id, created_by, expense_request, description,
+			frequency, interval_count, start_date, end_date, max_occurrences, next_date)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		r.TemplateID, r.GroupID, r.CreatedBy, string(r.Expense), r.Description,
+		r.Frequency, r.Interval, r.StartDate, r.EndDate, r.MaxOccurrences, r.NextDate,
+	)
+	return err
+}
+
+// GetRecurringExpense returns a single template
+func GetRecurringExpense(templateID string) (*RecurringExpense, error) {
+	templates, err := listRecurringExpenses("template_id = ?", templateID)
+	if err != nil {
+		return nil, err
+	}
+	if len(templates) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &templates[0], nil
+}
+
+// GetGroupRecurringExpenses returns a group's templates, soonest due first
+// and finished ones last
+func GetGroupRecurringExpenses(groupID string) ([]RecurringExpense, error) {
+	return listRecurringExpenses("group_id = ?", groupID)
+}
+
+// GetDueRecurringExpenses returns the templates with an occurrence due on or
+// before date
+func GetDueRecurringExpenses(date string) ([]RecurringExpense, error) {
+	return listRecurringExpenses("paused = 0 AND next_date != '' AND next_date <= ?", date)
+}
+
+// AdvanceRecurringExpense records that occurrence number occurrences-1 has
+// been created. It only moves a template that is still at that occurrence, so
+// a second run cannot skip one.
+func AdvanceRecurringExpense(templateID string, occurrences int, nextDate string) error {
+	_, err := DB.Exec(
+		"UPDATE recurring_expenses SET occurrences = ?, next_date = ?, last_error = '' WHERE template_id = ? AND occurrences = ?",
+		occurrences, nextDate, templateID, occurrences-1,
+	)
+	return err
+}
+
+// PauseRecurringExpense stops a template that cannot create its expense
+func PauseRecurringExpense(templateID, reason string) error {
+	_, err := DB.Exec("UPDATE recurring_expenses SET paused = 1, last_error = ? WHERE template_id = ?", reason, templateID)
+	return err
+}
+
+// ResumeRecurringExpense replaces the expense a template creates and clears
+// any pause, so occurrences missed while paused are created on the next run
+func ResumeRecurringExpense(templateID string, expense json.RawMessage, description string) error {
+	_, err := DB.Exec(
+		"UPDATE recurring_expenses SET expense_request = ?, description = ?, paused = 0, last_error = '' WHERE template_id = ?",
+		string(expense), description, templateID,
+	)
+	return err
+}
+
+// DeleteRecurringExpense removes a template; expenses it already created stay
+func DeleteRecurringExpense(templateID string) error {
+	_, err := DB.Exec("DELETE FROM recurring_expenses WHERE template_id = ?", templateID)
+	return err
+}
+
+func listRecurringExpenses(condition string, args ...interface{}) ([]RecurringExpense, error) {
+	rows, err := DB.Query(`
+		SELECT template_id, group_id, created_by, expense_request, description, frequency, interval_count,
+			   start_date, end_date, max_occurrences, occurrences, next_date, paused, last_error, date_created
+		FROM recurring_expenses
+		WHERE `+condition+`
+		ORDER BY next_date = '', next_date, date_created
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := make([]RecurringExpense, 0)
+	for rows.Next() {
+		r := RecurringExpense{}
+		var request string
+		if err := rows.Scan(
+			&r.TemplateID, &r.GroupID, &r.CreatedBy, &request, &r.Description, &r.Frequency, &r.Interval,
+			&r.StartDate, &r.EndDate, &r.MaxOccurrences, &r.Occurrences, &r.NextDate, &r.Paused, &r.LastError, &r.DateCreated,
+		); err != nil {
+			return nil, err
+		}
+		r.Expense = json.RawMessage(request)
+		templates = append(templates, r)
+	}
+	return templates, rows.Err()
+}
@@ -0,0 +1,346 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"splitwise/main/internal/entity"
+	"time"
+)
+
+// Settlement statuses. A payment only affects balances once the other party
+// confirms it.
+const (
+	SettlementPending   = "pending"
+	SettlementConfirmed = "confirmed"
+	SettlementDisputed  = "disputed"
+)
+
+// Settlement kinds. A payment is money that changed hands; an offset records
+// that, as part of a cross-group settlement, a debt the payee owed the payer
+// was cancelled against the payer's debts instead of being paid.
+const (
+	SettlementPayment = "payment"
+	SettlementOffset  = "offset"
+)
+
+var (
+	ErrSettlementNotPending = errors.New("settlement is no longer pending")
+	ErrNotSettlementPayee   = errors.New("only the other party can confirm or dispute a settlement")
+)
+
+// SettlementRecord is a payment from one member to another within a group
+type SettlementRecord struct {
+	SettlementID string       `json:"settlement_id"`
+	GroupID      string       `json:"group_id"`
+	GroupName    string       `json:"group_name"`
+	FromUserID   string       `json:"from_user_id"`
+	FromUserName string       `json:"from_user_name"`
+	ToUserID     string       `json:"to_user_id"`
+	ToUserName   string       `json:"to_user_name"`
+	Amount       entity.Money `json:"amount"`
+	// Currency is the group's base currency, which settlements are paid in
+	Currency entity.Currency `json:"currency"`
+	Note     string          `json:"note,omitempty"`
+	Method   string          `json:"method,omitempty"`
+	Mode     SettleMode      `json:"mode"`
+	Status   string          `json:"status"`
+	// RecordedBy is the member who recorded the payment; the other one confirms
+	RecordedBy string `json:"recorded_by"`
+	// BatchID groups the parts of a cross-group settlement, confirmed together
+	BatchID string `json:"batch_id,omitempty"`
+	// Kind is SettlementPayment or SettlementOffset
+	Kind          string     `json:"kind"`
+	DisputeReason string     `json:"dispute_reason,omitempty"`
+	DateCreated   time.Time  `json:"date_created"`
+	ConfirmedAt   *time.Time `json:"confirmed_at,omitempty"`
+}
+
+// SettleMode says how a settlement amount must relate to the current debt
+type SettleMode string
+
+const (
+	// SettlePartial pays part or all of the debt, never more
+	SettlePartial SettleMode = "partial"
+	// SettleExact pays off the debt to the cent; a zero amount means the full debt
+	SettleExact SettleMode = "exact"
+	// SettleOverpay may pay more than the debt, leaving the payee owing the rest
+	SettleOverpay SettleMode = "overpayment"
+)
+
+// ParseSettleMode maps a request's mode to a SettleMode, defaulting to partial
+func ParseSettleMode(mode string) (SettleMode, bool) {
+	switch SettleMode(mode) {
+	case "", SettlePartial:
+		return SettlePartial, true
+	case SettleExact, SettleOverpay:
+		return SettleMode(mode), true
+	}
+	return "", false
+}
+
+// SettlementError explains why a settlement does not fit the current balance
+type SettlementError struct {
+	Field   string
+	Message string
+}
+
+func (e *SettlementError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// CreateSettlement records that fromUser says they paid toUser amount in a
+// group. The amount is checked against what fromUser currently owes according
+// to mode, only here and not again on confirmation, and the payment waits for toUser to confirm it before it touches
+// balances. It returns the amount recorded.
+func CreateSettlement(settlementID, groupID, fromUserID, toUserID string, amount entity.Money, note, method string, mode SettleMode) (entity.Money, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return entity.Money{}, err
+	}
+	defer tx.Rollback()
+
+	amount, err = checkSettlement(tx, groupID, fromUserID, toUserID, amount, mode)
+	if err != nil {
+		return entity.Money{}, err
+	}
+
+	err = insertSettlement(tx, &SettlementRecord{
+		SettlementID: settlementID,
+		GroupID:      groupID,
+		FromUserID:   fromUserID,
+		ToUserID:     toUserID,
+		Amount:       amount,
+		Note:         note,
+		Method:       method,
+		Mode:         mode,
+		RecordedBy:   fromUserID,
+	})
+	if err != nil {
+		return entity.Money{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return entity.Money{}, err
+	}
+	return amount, nil
+}
+
+// checkSettlement validates amount against what fromUser owes toUser right
+// now, returning the amount to settle
+func checkSettlement(tx *sql.Tx, groupID, fromUserID, toUserID string, amount entity.Money, mode SettleMode) (entity.Money, error) {
+	var owed int64
+	err := tx.QueryRow(
+		"SELECT amount FROM balances WHERE group_id = ? AND from_user_id = ? AND to_user_id = ?",
+		groupID, fromUserID, toUserID,
+	).Scan(&owed)
+	if err != nil && err != sql.ErrNoRows {
+		return entity.Money{}, err
+	}
+	owedMoney := entity.NewMoney(owed, amount.Currency)
+	if owed <= 0 {
+		return entity.Money{}, &SettlementError{Field: "to_user_id", Message: "is not owed anything by you in this group"}
+	}
+
+	switch mode {
+	case SettleExact:
+		if amount.IsZero() {
+			amount = owedMoney
+		} else if amount.Amount != owed {
+			return entity.Money{}, &SettlementError{Field: "amount", Message: "must be exactly " + owedMoney.Decimal() + " to pay off the debt"}
+		}
+	case SettleOverpay:
+		// Anything goes; paying more than owed flips the debt
+	default:
+		if amount.Amount > owed {
+			return entity.Money{}, &SettlementError{Field: "amount", Message: "is more than the " + owedMoney.Decimal() + " you owe; use mode \"overpayment\" to pay more"}
+		}
+	}
+	if !amount.IsPositive() {
+		return entity.Money{}, &SettlementError{Field: "amount", Message: "must be a positive amount"}
+	}
+	return amount, nil
+}
+
+// insertSettlement stores a pending settlement within tx, as a payment unless
+// it says otherwise
+func insertSettlement(tx *sql.Tx, s *SettlementRecord) error {
+	if s.Kind == "" {
+		s.Kind = SettlementPayment
+	}
+	_, err := tx.Exec(`
+		INSERT INTO settlements (settlement_id, group_id, from_user_id, to_user_id, amount, currency,
+			note, method, mode, status, recorded_by, batch_id, kind)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		s.SettlementID, s.GroupID, s.FromUserID, s.ToUserID, s.Amount.Amount, s.Amount.Currency,
+		s.Note, s.Method, s.Mode, SettlementPending, s.RecordedBy, s.BatchID, s.Kind,
+	)
+	return err
+}
+
+// ConfirmSettlement is called by the party who did not record a pending
+// settlement. It applies the payment, together with every other part of its
+// batch. The amount was checked against the debt when it was recorded; the
+// money has changed hands since, so it is applied as recorded even if the
+// balance moved in between, leaving any difference owed one way or the other.
+func ConfirmSettlement(settlementID, userID string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	parts, err := pendingSettlementParts(tx, settlementID, userID)
+	if err != nil {
+		return err
+	}
+	for _, part := range parts {
+		entryType := LedgerSettlement
+		if part.Kind == SettlementOffset {
+			entryType = LedgerOffset
+		}
+		if err := settleInTx(tx, entryType, part.SettlementID, part.GroupID, part.FromUserID, part.ToUserID, part.Amount); err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			"UPDATE settlements SET status = ?, confirmed_at = CURRENT_TIMESTAMP WHERE settlement_id = ?",
+			SettlementConfirmed, part.SettlementID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DisputeSettlement is called by the party who did not record a pending
+// settlement to reject it. Balances are left untouched.
+func DisputeSettlement(settlementID, userID, reason string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	parts, err := pendingSettlementParts(tx, settlementID, userID)
+	if err != nil {
+		return err
+	}
+	for _, part := range parts {
+		_, err = tx.Exec(
+			"UPDATE settlements SET status = ?, dispute_reason = ? WHERE settlement_id = ?",
+			SettlementDisputed, reason, part.SettlementID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// pendingSettlementParts loads a pending settlement and the rest of its batch,
+// checking userID is the party who should confirm it
+func pendingSettlementParts(tx *sql.Tx, settlementID, userID string) ([]SettlementRecord, error) {
+	var batchID string
+	err := tx.QueryRow("SELECT batch_id FROM settlements WHERE settlement_id = ?", settlementID).Scan(&batchID)
+	if err != nil {
+		return nil, err
+	}
+
+	condition, arg := "settlement_id = ?", settlementID
+	if batchID != "" {
+		condition, arg = "batch_id = ?", batchID
+	}
+	rows, err := tx.Query(`
+		SELECT settlement_id, group_id, from_user_id, to_user_id, amount, currency, mode, status, recorded_by, batch_id, kind
+		FROM settlements WHERE `+condition+` ORDER BY settlement_id`, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	parts := make([]SettlementRecord, 0)
+	for rows.Next() {
+		s := SettlementRecord{}
+		if err := rows.Scan(
+			&s.SettlementID, &s.GroupID, &s.FromUserID, &s.ToUserID, &s.Amount.Amount, &s.Amount.Currency,
+			&s.Mode, &s.Status, &s.RecordedBy, &s.BatchID, &s.Kind,
+		); err != nil {
+			return nil, err
+		}
+		if s.Status != SettlementPending {
+			return nil, ErrSettlementNotPending
+		}
+		if userID == s.RecordedBy || (userID != s.FromUserID && userID != s.ToUserID) {
+			return nil, ErrNotSettlementPayee
+		}
+		parts = append(parts, s)
+	}
+	return parts, rows.Err()
+}
+
+// partSettlementID names the part of a cross-group settlement applied to one
+// group
+func partSettlementID(settlementID string, part int) string {
+	return fmt.Sprintf("%s-%d", settlementID, part+1)
+}
+
+// GetGroupSettlements returns a group's settlements, newest first
+func GetGroupSettlements(groupID string) ([]SettlementRecord, error) {
+	return listSettlements("s.group_id = ?", groupID)
+}
+
+// GetUserSettlements returns every settlement the user paid or received,
+// newest first
+func GetUserSettlements(userID string) ([]SettlementRecord, error) {
+	return listSettlements("(s.from_user_id = ? OR s.to_user_id = ?)", userID, userID)
+}
+
+// GetPendingSettlements returns pending settlements waiting for the user to
+// confirm or dispute them
+func GetPendingSettlements(userID string) ([]SettlementRecord, error) {
+	return listSettlements(
+		"s.status = ? AND (s.from_user_id = ? OR s.to_user_id = ?) AND s.recorded_by != ?",
+		SettlementPending, userID, userID, userID,
+	)
+}
+
+func listSettlements(condition string, args ...interface{}) ([]SettlementRecord, error) {
+	rows, err := DB.Query(`
+		SELECT s.settlement_id, s.group_id, g.group_name, s.from_user_id, u1.user_name,
+			   s.to_user_id, u2.user_name, s.amount, s.currency, s.note, s.method,
+			   s.mode, s.status, s.recorded_by, s.batch_id, s.kind, s.dispute_reason, s.date_created, s.confirmed_at
+		FROM settlements s
+		JOIN groups g ON s.group_id = g.group_id
+		JOIN users u1 ON s.from_user_id = u1.user_id
+		JOIN users u2 ON s.to_user_id = u2.user_id
+		WHERE `+condition+`
+		ORDER BY s.date_created DESC, s.settlement_id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	settlements := make([]SettlementRecord, 0)
+	for rows.Next() {
+		s := SettlementRecord{}
+		var confirmedAt sql.NullTime
+		if err := rows.Scan(
+			&s.SettlementID, &s.GroupID, &s.GroupName, &s.FromUserID, &s.FromUserName,
+			&s.ToUserID, &s.ToUserName, &s.Amount.Amount, &s.Amount.Currency, &s.Note, &s.Method,
+			&s.Mode, &s.Status, &s.RecordedBy, &s.BatchID, &s.Kind, &s.DisputeReason, &s.DateCreated, &confirmedAt,
+		); err != nil {
+			return nil, err
+		}
+		s.Currency = s.Amount.Currency
+		if confirmedAt.Valid {
+			s.ConfirmedAt = &confirmedAt.Time
+		}
+		settlements = append(settlements, s)
+	}
+	return settlements, rows.Err()
+}
@@ -7,6 +7,11 @@ type Group struct {
 	GroupName    string    `json:"group_name"`
 	GroupMembers []*User   `json:"group_members"`
 	DateCreated  time.Time `json:"date_created"`
+	// SimplifyDebts shows the group's debts simplified by default
+	SimplifyDebts bool `json:"simplify_debts"`
+	// BaseCurrency is what the group's balances are kept in; expenses in
+	// other currencies are converted into it
+	BaseCurrency Currency `json:"base_currency"`
 }
 
 func NewGroup(groupID, groupName string, groupMembers []*User) *Group {
@@ -14,6 +19,7 @@ func NewGroup(groupID, groupName string, groupMembers []*User) *Group {
 		GroupID:      groupID,
 		GroupName:    groupName,
 		GroupMembers: groupMembers,
+		BaseCurrency: DefaultCurrency,
 	}
 }
 
@@ -29,3 +35,13 @@ func (g *Group) GetGroupMembers() []*User {
 func (g *Group) GetDateCreated() time.Time {
 	return g.DateCreated
 }
+
+// GetMember returns the member with the given ID, or nil if they are not in the group
+func (g *Group) GetMember(userID string) *User {
+	for _, member := range g.GroupMembers {
+		if member.UserID == userID {
+			return member
+		}
+	}
+	return nil
+}
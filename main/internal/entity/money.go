@@ -0,0 +1,310 @@
+package entity
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Currency is an ISO 4217 currency code
+type Currency string
+
+// DefaultCurrency is used wherever an amount carries no explicit currency
+const DefaultCurrency Currency = "USD"
+
+// Currencies without a minor unit; every other currency uses two decimals
+var zeroDecimalCurrencies = map[Currency]bool{
+	"JPY": true,
+	"KRW": true,
+	"VND": true,
+	"CLP": true,
+	"ISK": true,
+}
+
+var (
+	ErrInvalidAmount   = errors.New("invalid amount")
+	ErrInvalidCurrency = errors.New("invalid currency code")
+)
+
+// ParseCurrency reads a three letter ISO 4217 code, ignoring case
+func ParseCurrency(code string) (Currency, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 {
+		return "", ErrInvalidCurrency
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return "", ErrInvalidCurrency
+		}
+	}
+	return Currency(code), nil
+}
+
+// Exponent returns the number of decimal places in the currency's minor unit
+func (c Currency) Exponent() int {
+	if zeroDecimalCurrencies[c] {
+		return 0
+	}
+	return 2
+}
+
+func (c Currency) minorPerMajor() int64 {
+	factor := int64(1)
+	for i := 0; i < c.Exponent(); i++ {
+		factor *= 10
+	}
+	return factor
+}
+
+// Money is an amount held as integer minor units (cents, paise) of a currency
+type Money struct {
+	Amount   int64
+	Currency Currency
+}
+
+func NewMoney(minorUnits int64, currency Currency) Money {
+	return Money{Amount: minorUnits, Currency: currency}
+}
+
+// ParseMoney parses a decimal string such as "1000", "333.34" or "2.5e1"
+// exactly, rejecting values with more decimals than the currency allows
+func ParseMoney(s string, currency Currency) (Money, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	mantissa, exponent, hasExponent := strings.Cut(strings.ToLower(s), "e")
+	whole, frac, _ := strings.Cut(mantissa, ".")
+	if hasExponent {
+		var ok bool
+		if whole, frac, ok = shiftDecimalPoint(whole, frac, exponent); !ok {
+			return Money{}, ErrInvalidAmount
+		}
+	}
+	if whole == "" && frac == "" {
+		return Money{}, ErrInvalidAmount
+	}
+	if len(frac) > currency.Exponent() {
+		return Money{}, ErrInvalidAmount
+	}
+	frac += strings.Repeat("0", currency.Exponent()-len(frac))
+
+	digits := whole + frac
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return Money{}, ErrInvalidAmount
+		}
+	}
+	minor, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return Money{}, ErrInvalidAmount
+	}
+	if negative {
+		minor = -minor
+	}
+	return NewMoney(minor, currency), nil
+}
+
+// shiftDecimalPoint applies a JSON style exponent such as "3" or "-2" to the
+// digits around a decimal point, moving the point instead of going through a
+// float so the result stays exact
+func shiftDecimalPoint(whole, frac, exponent string) (string, string, bool) {
+	exp, err := strconv.Atoi(strings.TrimPrefix(exponent, "+"))
+	if err != nil || whole+frac == "" || exp < -18 || exp > 18 {
+		return "", "", false
+	}
+	digits := whole + frac
+	point := len(whole) + exp
+	if point < 0 {
+		digits = strings.Repeat("0", -point) + digits
+		point = 0
+	}
+	if point > len(digits) {
+		digits += strings.Repeat("0", point-len(digits))
+	}
+	return digits[:point], digits[point:], true
+}
+
+// MoneyFromMajor converts a float amount in major units, rounding to the
+// nearest minor unit. Only use it at boundaries where a float is unavoidable.
+func MoneyFromMajor(amount float64, currency Currency) Money {
+	scaled := amount * float64(currency.minorPerMajor())
+	if scaled < 0 {
+		return NewMoney(int64(scaled-0.5), currency)
+	}
+	return NewMoney(int64(scaled+0.5), currency)
+}
+
+// Convert returns m in currency to, where rate is how many units of to one
+// unit of m's currency buys, rounded to the nearest minor unit of to
+func (m Money) Convert(rate float64, to Currency) Money {
+	if m.Currency == to {
+		return m
+	}
+	return MoneyFromMajor(m.Major()*rate, to)
+}
+
+func (m Money) currency(other Money) Currency {
+	if m.Currency == "" {
+		return other.Currency
+	}
+	return m.Currency
+}
+
+// Add returns m + other; both are expected to share a currency
+func (m Money) Add(other Money) Money {
+	return NewMoney(m.Amount+other.Amount, m.currency(other))
+}
+
+// Sub returns m - other; both are expected to share a currency
+func (m Money) Sub(other Money) Money {
+	return NewMoney(m.Amount-other.Amount, m.currency(other))
+}
+
+func (m Money) Neg() Money {
+	return NewMoney(-m.Amount, m.Currency)
+}
+
+func (m Money) Abs() Money {
+	if m.Amount < 0 {
+		return m.Neg()
+	}
+	return m
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// Allocate splits m into n parts that differ by at most one minor unit and
+// always sum back to m. The leftover units go to the first parts, so callers
+// decide who absorbs them through the order they allocate in. A negative m
+// gives its leftover units, as negative ones, to the same first parts.
+func (m Money) Allocate(n int) []Money {
+	if n <= 0 {
+		return nil
+	}
+	parts := make([]Money, n)
+	share := m.Amount / int64(n)
+	remainder := m.Amount % int64(n)
+	unit := int64(1)
+	if remainder < 0 {
+		remainder, unit = -remainder, -1
+	}
+	for i := range parts {
+		parts[i] = NewMoney(share, m.Currency)
+		if int64(i) < remainder {
+			parts[i].Amount += unit
+		}
+	}
+	return parts
+}
+
+// AllocateByWeights splits m in proportion to weights using the largest
+// remainder method, so the parts always sum back to m. Ties for the leftover
+// minor units go to the earlier weight.
+func (m Money) AllocateByWeights(weights []int64) []Money {
+	parts := make([]Money, len(weights))
+	var totalWeight int64
+	for _, w := range weights {
+		totalWeight += w
+	}
+	if totalWeight <= 0 {
+		for i := range parts {
+			parts[i] = NewMoney(0, m.Currency)
+		}
+		return parts
+	}
+
+	sign := int64(1)
+	amount := m.Amount
+	if amount < 0 {
+		sign, amount = -1, -amount
+	}
+
+	remainders := make([]int64, len(weights))
+	allocated := int64(0)
+	for i, w := range weights {
+		share := amount * w / totalWeight
+		remainders[i] = amount * w % totalWeight
+		parts[i] = NewMoney(share, m.Currency)
+		allocated += share
+	}
+
+	for leftover := amount - allocated; leftover > 0; leftover-- {
+		best := -1
+		for i := range weights {
+			if weights[i] > 0 && (best == -1 || remainders[i] > remainders[best]) {
+				best = i
+			}
+		}
+		parts[best].Amount++
+		remainders[best] = -1
+	}
+
+	for i := range parts {
+		parts[i].Amount *= sign
+	}
+	return parts
+}
+
+// Major returns the amount in major units for display purposes only
+func (m Money) Major() float64 {
+	return float64(m.Amount) / float64(m.Currency.minorPerMajor())
+}
+
+// Decimal formats the amount in major units, e.g. "333.34"
+func (m Money) Decimal() string {
+	currency := m.Currency
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	factor := currency.minorPerMajor()
+	whole := strconv.FormatInt(amount/factor, 10)
+	if currency.Exponent() == 0 {
+		return sign + whole
+	}
+	frac := strconv.FormatInt(amount%factor, 10)
+	frac = strings.Repeat("0", currency.Exponent()-len(frac)) + frac
+	return sign + whole + "." + frac
+}
+
+func (m Money) String() string {
+	currency := m.Currency
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	return m.Decimal() + " " + string(currency)
+}
+
+// MarshalJSON encodes money as a plain decimal number so API clients keep
+// receiving amounts in major units
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.Decimal()), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	currency := m.Currency
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	parsed, err := ParseMoney(strings.Trim(string(data), `"`), currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
@@ -0,0 +1,97 @@
+package entity
+
+import "testing"
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount Money
+		n      int
+		want   []int64
+	}{
+		{"even", NewMoney(900, "USD"), 3, []int64{300, 300, 300}},
+		{"odd rupees", NewMoney(100000, "INR"), 3, []int64{33334, 33333, 33333}},
+		{"one cent three ways", NewMoney(1, "USD"), 3, []int64{1, 0, 0}},
+		{"two cents three ways", NewMoney(2, "USD"), 3, []int64{1, 1, 0}},
+		{"zero decimal currency", NewMoney(1000, "JPY"), 3, []int64{334, 333, 333}},
+		{"negative", NewMoney(-100, "USD"), 3, []int64{-34, -33, -33}},
+		{"single part", NewMoney(12345, "USD"), 1, []int64{12345}},
+		{"zero", NewMoney(0, "USD"), 4, []int64{0, 0, 0, 0}},
+		{"no parts", NewMoney(100, "USD"), 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := tt.amount.Allocate(tt.n)
+			if len(parts) != len(tt.want) {
+				t.Fatalf("got %d parts, want %d", len(parts), len(tt.want))
+			}
+			for i, part := range parts {
+				if part.Amount != tt.want[i] || part.Currency != tt.amount.Currency {
+					t.Errorf("part %d = %v, want %d %s", i, part, tt.want[i], tt.amount.Currency)
+				}
+			}
+		})
+	}
+}
+
+func TestAllocateLargeGroups(t *testing.T) {
+	for _, n := range []int{50, 51, 97, 500} {
+		for _, amount := range []int64{1, 99, 100001, 123456789, -100001} {
+			parts := NewMoney(amount, "USD").Allocate(n)
+
+			sum, lo, hi := int64(0), parts[0].Amount, parts[0].Amount
+			for _, part := range parts {
+				sum += part.Amount
+				lo, hi = min(lo, part.Amount), max(hi, part.Amount)
+			}
+			if sum != amount {
+				t.Errorf("Allocate(%d) of %d sums to %d", n, amount, sum)
+			}
+			if hi-lo > 1 {
+				t.Errorf("Allocate(%d) of %d has parts from %d to %d", n, amount, lo, hi)
+			}
+		}
+	}
+}
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		input    string
+		currency Currency
+		want     int64
+		wantErr  bool
+	}{
+		{"1000", "USD", 100000, false},
+		{"333.34", "USD", 33334, false},
+		{".5", "USD", 50, false},
+		{"-12.5", "USD", -1250, false},
+		{"1000", "JPY", 1000, false},
+		{"1e3", "USD", 100000, false},
+		{"2.5E1", "USD", 2500, false},
+		{"1.5e+2", "USD", 15000, false},
+		{"12345e-2", "USD", 12345, false},
+		{"-4e-2", "USD", -4, false},
+		{"1e3", "JPY", 1000, false},
+		{"1.23", "JPY", 0, true},
+		{"1.234", "USD", 0, true},
+		{"1e-3", "USD", 0, true},
+		{"5e-1", "JPY", 0, true},
+		{"e3", "USD", 0, true},
+		{"1e", "USD", 0, true},
+		{"1e99", "USD", 0, true},
+		{"abc", "USD", 0, true},
+		{"", "USD", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.input, tt.currency)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseMoney(%q, %s) = %v, want error", tt.input, tt.currency, got)
+			}
+			continue
+		}
+		if err != nil || got.Amount != tt.want || got.Currency != tt.currency {
+			t.Errorf("ParseMoney(%q, %s) = %v, %v, want %d", tt.input, tt.currency, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package entity
+
+// Payer is one member's contribution towards paying an expense
+type Payer struct {
+	User   *User `json:"user"`
+	Amount Money `json:"amount"`
+}
+
+func NewPayer(user *User, amount Money) *Payer {
+	return &Payer{
+		User:   user,
+		Amount: amount,
+	}
+}
+func (p *Payer) GetUser() *User {
+	return p.User
+}
+func (p *Payer) GetAmount() Money {
+	return p.Amount
+}
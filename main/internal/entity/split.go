@@ -1,11 +1,11 @@
 package entity
 
 type Split struct {
-	User   *User   `json:"user"`
-	Amount float64 `json:"amount"`
+	User   *User `json:"user"`
+	Amount Money `json:"amount"`
 }
 
-func NewSplit(user *User, amount float64) *Split {
+func NewSplit(user *User, amount Money) *Split {
 	return &Split{
 		User:   user,
 		Amount: amount,
@@ -14,6 +14,6 @@ func NewSplit(user *User, amount float64) *Split {
 func (s *Split) GetUser() *User {
 	return s.User
 }
-func (s *Split) GetAmount() float64 {
+func (s *Split) GetAmount() Money {
 	return s.Amount
 }
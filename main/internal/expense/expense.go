@@ -8,28 +8,33 @@ import (
 type Expense struct {
 	ExpenseID          string          `json:"expense_id"`
 	ExpenseDescription string          `json:"expense_description"`
-	ExpenseAmount      float64         `json:"expense_amount"`
+	ExpenseAmount      entity.Money    `json:"expense_amount"`
 	Group              *entity.Group   `json:"group"`
 	PaidBy             *entity.User    `json:"user"`
+	Payers             []*entity.Payer `json:"payers"`
 	Splits             []*entity.Split `json:"splits"`
-	DateCreated        time.Time       `json:"date_created"`
+	// ExpenseDate is the day the expense happened; DateCreated is when it was entered
+	ExpenseDate time.Time `json:"expense_date"`
+	DateCreated time.Time `json:"date_created"`
 }
 
-func NewExpense(expenseID, expenseDescription string, expenseAmount float64, group *entity.Group, paidBy *entity.User, splits []*entity.Split) *Expense {
+func NewExpense(expenseID, expenseDescription string, expenseAmount entity.Money, group *entity.Group, paidBy *entity.User, splits []*entity.Split, expenseDate time.Time) *Expense {
 	return &Expense{
 		ExpenseID:          expenseID,
 		ExpenseDescription: expenseDescription,
 		ExpenseAmount:      expenseAmount,
 		Group:              group,
 		PaidBy:             paidBy,
+		Payers:             []*entity.Payer{entity.NewPayer(paidBy, expenseAmount)},
 		Splits:             splits,
+		ExpenseDate:        expenseDate,
 		DateCreated:        time.Now(),
 	}
 }
-func (e *Expense) GetAmount() float64 {
-	totalAmount := 0.0
+func (e *Expense) GetAmount() entity.Money {
+	totalAmount := entity.NewMoney(0, e.ExpenseAmount.Currency)
 	for _, split := range e.Splits {
-		totalAmount += split.Amount
+		totalAmount = totalAmount.Add(split.Amount)
 	}
 	return totalAmount
 }
@@ -37,12 +42,18 @@ func (e *Expense) GetAmount() float64 {
 func (e *Expense) GetPaidBy() *entity.User {
 	return e.PaidBy
 }
+func (e *Expense) GetPayers() []*entity.Payer {
+	return e.Payers
+}
 func (e *Expense) GetSplits() []*entity.Split {
 	return e.Splits
 }
 func (e *Expense) GetDateCreated() time.Time {
 	return e.DateCreated
 }
+func (e *Expense) GetExpenseDate() time.Time {
+	return e.ExpenseDate
+}
 func (e *Expense) GetGroup() *entity.Group {
 	return e.Group
 }
@@ -52,6 +63,6 @@ func (e *Expense) GetExpenseID() string {
 func (e *Expense) GetExpenseDescription() string {
 	return e.ExpenseDescription
 }
-func (e *Expense) GetExpenseAmount() float64 {
+func (e *Expense) GetExpenseAmount() entity.Money {
 	return e.ExpenseAmount
 }
@@ -5,6 +5,7 @@ import (
 	"splitwise/main/internal/entity"
 	"splitwise/main/internal/expense"
 	"splitwise/main/internal/stragegy"
+	"time"
 )
 
 type SplitWiseService struct {
@@ -35,15 +36,18 @@ func (s *SplitWiseService) CreateGroup(groupID, groupName string, groupMembers [
 	return group
 }
 
-func (s *SplitWiseService) AddExpense(expenseID, expenseDescription string, expenseAmount float64, paidByUserID string, groupID string, splitType stragegy.SplitType, splitData map[entity.User]float64) *expense.Expense {
+func (s *SplitWiseService) AddExpense(expenseID, expenseDescription string, expenseAmount entity.Money, paidByUserID string, groupID string, splitType stragegy.SplitType, splitData map[entity.User]float64, expenseDate time.Time) *expense.Expense {
 	paidBy := s.getUserByID(paidByUserID)
 	group := s.getGroupByID(groupID)
-	splits := stragegy.GetSplitStrategy(splitType, group).CalculateSplits(splitData, expenseAmount)
-	if paidBy == nil || group == nil || splits == nil {
+	if paidBy == nil || group == nil {
 		return nil
 	}
-	expense := expense.NewExpense(expenseID, expenseDescription, expenseAmount, group, paidBy, splits)
-	s.BalanceSheet.UpdateBalance(paidBy, splits)
+	splits, err := stragegy.GetSplitStrategy(splitType, group).CalculateSplits(splitData, expenseAmount)
+	if err != nil {
+		return nil
+	}
+	expense := expense.NewExpense(expenseID, expenseDescription, expenseAmount, group, paidBy, splits, expenseDate)
+	s.BalanceSheet.UpdateBalanceForPayers(expense.GetPayers(), splits)
 	s.Expenses = append(s.Expenses, expense)
 	return expense
 }
@@ -64,7 +68,7 @@ func (s *SplitWiseService) getGroupByID(groupID string) *entity.Group {
 	return nil
 }
 
-func (s *SplitWiseService) Settle(fromUserID, toUserID string, amount float64) {
+func (s *SplitWiseService) Settle(fromUserID, toUserID string, amount entity.Money) {
 	fromUser := s.getUserByID(fromUserID)
 	toUser := s.getUserByID(toUserID)
 	if fromUser == nil || toUser == nil {
@@ -88,6 +92,7 @@ func (s *SplitWiseService) PrintBalanceForUser(userID string) {
 func (s *SplitWiseService) DeleteExpense(expenseID string) {
 	for i, expense := range s.Expenses {
 		if expense.GetExpenseID() == expenseID {
+			s.BalanceSheet.RevertBalanceForPayers(expense.GetPayers(), expense.GetSplits())
 			s.Expenses = append(s.Expenses[:i], s.Expenses[i+1:]...)
 			break
 		}
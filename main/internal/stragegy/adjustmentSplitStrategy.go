@@ -0,0 +1,67 @@
+package stragegy
+
+import "splitwise/main/internal/entity"
+
+type AdjustmentSplitStrategy struct {
+	Group *entity.Group `json:"group"`
+}
+
+func NewAdjustmentSplitStrategy(group *entity.Group) *AdjustmentSplitStrategy {
+	return &AdjustmentSplitStrategy{
+		Group: group,
+	}
+}
+
+// CalculateSplits applies the per-member adjustments in splitData (e.g. +200
+// for an extra drink, -50 for skipping dessert) and splits what is left of the
+// total equally across the whole group. Each member pays their equal share
+// plus their adjustment, so the splits always sum to the total.
+func (a *AdjustmentSplitStrategy) CalculateSplits(splitData map[entity.User]float64, totalAmount entity.Money) ([]*entity.Split, error) {
+	if errs := validateSplitMembers(a.Group, splitData, totalAmount); len(errs) > 0 {
+		return nil, errs
+	}
+
+	members := sortMembersByID(a.Group.GetGroupMembers())
+	if len(members) == 0 {
+		return nil, SplitErrors{NewSplitError("group_id", "group has no members")}
+	}
+
+	errs := make(SplitErrors, 0)
+	adjustments := make([]entity.Money, len(members))
+	remaining := totalAmount
+	for i, member := range members {
+		adjustment, ok := moneyFromSplitValue(splitData[*member], totalAmount.Currency)
+		if !ok {
+			errs = append(errs, NewSplitError(member.UserID, "has more decimal places than the currency allows"))
+			continue
+		}
+		adjustments[i] = adjustment
+		remaining = remaining.Sub(adjustment)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if remaining.IsNegative() {
+		return nil, SplitErrors{NewSplitError("split_data", "adjustments add up to more than the expense total")}
+	}
+
+	splits := make([]*entity.Split, 0)
+	for i, share := range remaining.Allocate(len(members)) {
+		amount := share.Add(adjustments[i])
+		if amount.IsNegative() {
+			errs = append(errs, NewSplitError(members[i].UserID, "adjustment leaves a negative share"))
+			continue
+		}
+		if amount.IsZero() {
+			continue
+		}
+		splits = append(splits, entity.NewSplit(members[i], amount))
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return splits, nil
+}
+func (a *AdjustmentSplitStrategy) GetGroup() *entity.Group {
+	return a.Group
+}
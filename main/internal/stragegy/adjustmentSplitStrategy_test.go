@@ -0,0 +1,84 @@
+package stragegy
+
+import (
+	"splitwise/main/internal/entity"
+	"testing"
+)
+
+func TestAdjustmentSplitStrategy(t *testing.T) {
+	tests := []struct {
+		name        string
+		total       entity.Money
+		adjustments map[string]float64
+		want        map[string]int64
+	}{
+		{
+			name:        "extra drink",
+			total:       entity.NewMoney(10000, "USD"),
+			adjustments: map[string]float64{"user-001": 10},
+			want:        map[string]int64{"user-001": 4000, "user-002": 3000, "user-003": 3000},
+		},
+		{
+			name:        "skipped dessert",
+			total:       entity.NewMoney(10000, "USD"),
+			adjustments: map[string]float64{"user-002": -5},
+			want:        map[string]int64{"user-001": 3500, "user-002": 3000, "user-003": 3500},
+		},
+		{
+			name:        "remainder after adjustments",
+			total:       entity.NewMoney(10000, "USD"),
+			adjustments: map[string]float64{"user-003": 0.01},
+			want:        map[string]int64{"user-001": 3333, "user-002": 3333, "user-003": 3334},
+		},
+		{
+			name:  "no adjustments",
+			total: entity.NewMoney(1000, "USD"),
+			want:  map[string]int64{"user-001": 334, "user-002": 333, "user-003": 333},
+		},
+		{
+			name:        "adjustment covers the total",
+			total:       entity.NewMoney(3000, "USD"),
+			adjustments: map[string]float64{"user-001": 30},
+			want:        map[string]int64{"user-001": 3000},
+		},
+		{
+			name:        "zero decimal currency",
+			total:       entity.NewMoney(1000, "JPY"),
+			adjustments: map[string]float64{"user-002": 100},
+			want:        map[string]int64{"user-001": 300, "user-002": 400, "user-003": 300},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group := testGroup(3)
+			splits, err := NewAdjustmentSplitStrategy(group).CalculateSplits(testSplitData(group, tt.adjustments), tt.total)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkSplits(t, splits, tt.want, tt.total.Currency)
+		})
+	}
+}
+
+func TestAdjustmentSplitStrategyRejects(t *testing.T) {
+	tests := []struct {
+		name        string
+		total       entity.Money
+		adjustments map[string]float64
+		field       string
+	}{
+		{"adjustments over the total", entity.NewMoney(10000, "USD"), map[string]float64{"user-001": 200}, "split_data"},
+		{"negative share", entity.NewMoney(3000, "USD"), map[string]float64{"user-001": -50}, "user-001"},
+		{"too many decimals", entity.NewMoney(3000, "USD"), map[string]float64{"user-001": 0.001}, "user-001"},
+		{"decimals in a zero decimal currency", entity.NewMoney(3000, "JPY"), map[string]float64{"user-002": 0.5}, "user-002"},
+		{"outsider", entity.NewMoney(3000, "USD"), map[string]float64{"stranger": 1}, "stranger"},
+		{"zero total", entity.NewMoney(0, "USD"), nil, "expense_amount"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group := testGroup(3)
+			_, err := NewAdjustmentSplitStrategy(group).CalculateSplits(testSplitData(group, tt.adjustments), tt.total)
+			checkSplitError(t, err, tt.field)
+		})
+	}
+}
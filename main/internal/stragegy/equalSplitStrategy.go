@@ -1,6 +1,9 @@
 package stragegy
 
-import "splitwise/main/internal/entity"
+import (
+	"sort"
+	"splitwise/main/internal/entity"
+)
 
 type EqualSplitStrategy struct {
 	Group *entity.Group `json:"group"`
@@ -12,16 +15,55 @@ func NewEqualSplitStrategy(group *entity.Group) *EqualSplitStrategy {
 	}
 }
 
-func (e *EqualSplitStrategy) CalculateSplits(splitData map[entity.User]float64, totalAmount float64) []*entity.Split {
+// CalculateSplits divides the total evenly. When splitData is non-empty only
+// the members it names take part; otherwise the whole group does. Members are
+// ordered by user ID and the leftover minor units go one each to the first
+// members in that order, so the same expense always produces the same splits
+// and they sum to the total. This is deliberate: the members with the lowest
+// IDs pay the extra unit on every uneven expense, which is at most one minor
+// unit each, in exchange for splits that do not depend on who paid or when.
+func (e *EqualSplitStrategy) CalculateSplits(splitData map[entity.User]float64, totalAmount entity.Money) ([]*entity.Split, error) {
+	if errs := validateSplitInput(e.Group, splitData, totalAmount); len(errs) > 0 {
+		return nil, errs
+	}
+	participants := e.participants(splitData)
+	if len(participants) == 0 {
+		return nil, SplitErrors{NewSplitError("participants", "at least one member must take part")}
+	}
+
 	splits := make([]*entity.Split, 0)
-	groupMembers := e.Group.GetGroupMembers()
-	amountPerPerson := totalAmount / float64(len(groupMembers))
+	shares := totalAmount.Allocate(len(participants))
+	for i, member := range participants {
+		splits = append(splits, entity.NewSplit(member, shares[i]))
+	}
+	return splits, nil
+}
+
+func (e *EqualSplitStrategy) participants(splitData map[entity.User]float64) []*entity.User {
+	groupMembers := sortMembersByID(e.Group.GetGroupMembers())
+	if len(splitData) == 0 {
+		return groupMembers
+	}
+	participants := make([]*entity.User, 0)
 	for _, member := range groupMembers {
-		splits = append(splits, entity.NewSplit(member, amountPerPerson))
+		if _, ok := splitData[*member]; ok {
+			participants = append(participants, member)
+		}
 	}
-	return splits
+	return participants
 }
 
 func (e *EqualSplitStrategy) GetGroup() *entity.Group {
 	return e.Group
 }
+
+// sortMembersByID returns a copy of members in a stable order that does not
+// depend on how the database happened to return them
+func sortMembersByID(members []*entity.User) []*entity.User {
+	sorted := make([]*entity.User, len(members))
+	copy(sorted, members)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].UserID < sorted[j].UserID
+	})
+	return sorted
+}
@@ -0,0 +1,170 @@
+package stragegy
+
+import (
+	"errors"
+	"fmt"
+	"splitwise/main/internal/entity"
+	"testing"
+)
+
+func testGroup(n int) *entity.Group {
+	members := make([]*entity.User, 0, n)
+	// Added in reverse so the strategy has to order them itself
+	for i := n; i > 0; i-- {
+		id := fmt.Sprintf("user-%03d", i)
+		members = append(members, entity.NewUser(id, id, id+"@example.com"))
+	}
+	return entity.NewGroup("group", "Group", members)
+}
+
+// testSplitData keys values by member, or by a bare user for IDs outside the group
+func testSplitData(group *entity.Group, values map[string]float64) map[entity.User]float64 {
+	splitData := make(map[entity.User]float64)
+	for id, value := range values {
+		if member := group.GetMember(id); member != nil {
+			splitData[*member] = value
+		} else {
+			splitData[entity.User{UserID: id}] = value
+		}
+	}
+	return splitData
+}
+
+func checkSplits(t *testing.T, splits []*entity.Split, want map[string]int64, currency entity.Currency) {
+	t.Helper()
+	if len(splits) != len(want) {
+		t.Fatalf("got %d splits, want %d", len(splits), len(want))
+	}
+	for _, split := range splits {
+		amount, ok := want[split.User.UserID]
+		if !ok {
+			t.Errorf("unexpected split for %s", split.User.UserID)
+			continue
+		}
+		if split.Amount.Amount != amount || split.Amount.Currency != currency {
+			t.Errorf("%s owes %v, want %d %s", split.User.UserID, split.Amount, amount, currency)
+		}
+	}
+}
+
+func checkSplitError(t *testing.T, err error, field string) {
+	t.Helper()
+	var splitErrs SplitErrors
+	if !errors.As(err, &splitErrs) {
+		t.Fatalf("got %v, want SplitErrors", err)
+	}
+	if _, ok := splitErrs.Fields()[field]; !ok {
+		t.Errorf("errors %v do not mention %s", splitErrs.Fields(), field)
+	}
+}
+
+func TestEqualSplitStrategy(t *testing.T) {
+	tests := []struct {
+		name         string
+		members      int
+		total        entity.Money
+		participants []string
+		want         map[string]int64
+	}{
+		{
+			name:    "odd rupees",
+			members: 3,
+			total:   entity.NewMoney(100000, "INR"),
+			want:    map[string]int64{"user-001": 33334, "user-002": 33333, "user-003": 33333},
+		},
+		{
+			name:    "one cent three ways",
+			members: 3,
+			total:   entity.NewMoney(1, "USD"),
+			want:    map[string]int64{"user-001": 1, "user-002": 0, "user-003": 0},
+		},
+		{
+			name:    "even",
+			members: 4,
+			total:   entity.NewMoney(1000, "USD"),
+			want:    map[string]int64{"user-001": 250, "user-002": 250, "user-003": 250, "user-004": 250},
+		},
+		{
+			name:         "participant subset",
+			members:      4,
+			total:        entity.NewMoney(1000, "USD"),
+			participants: []string{"user-004", "user-002", "user-003"},
+			want:         map[string]int64{"user-002": 334, "user-003": 333, "user-004": 333},
+		},
+		{
+			name:         "single participant",
+			members:      4,
+			total:        entity.NewMoney(999, "USD"),
+			participants: []string{"user-003"},
+			want:         map[string]int64{"user-003": 999},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group := testGroup(tt.members)
+			splitData := make(map[entity.User]float64)
+			for _, id := range tt.participants {
+				splitData[*group.GetMember(id)] = 1
+			}
+
+			splits, err := NewEqualSplitStrategy(group).CalculateSplits(splitData, tt.total)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkSplits(t, splits, tt.want, tt.total.Currency)
+		})
+	}
+}
+
+func TestEqualSplitStrategyLargeGroups(t *testing.T) {
+	for _, members := range []int{50, 73, 200} {
+		group := testGroup(members)
+		for _, amount := range []int64{1, 4999, 100000, 987654321} {
+			total := entity.NewMoney(amount, "USD")
+			splits, err := NewEqualSplitStrategy(group).CalculateSplits(nil, total)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(splits) != members {
+				t.Fatalf("%d members: got %d splits", members, len(splits))
+			}
+
+			// Leftover units go to the lowest user IDs, one each
+			remainder := amount % int64(members)
+			sum := int64(0)
+			for i, split := range splits {
+				want := amount / int64(members)
+				if int64(i) < remainder {
+					want++
+				}
+				if split.User.UserID != fmt.Sprintf("user-%03d", i+1) || split.Amount.Amount != want {
+					t.Errorf("%d members, %d: split %d is %s %v, want %d", members, amount, i, split.User.UserID, split.Amount, want)
+				}
+				sum += split.Amount.Amount
+			}
+			if sum != amount {
+				t.Errorf("%d members: splits of %d sum to %d", members, amount, sum)
+			}
+		}
+	}
+}
+
+func TestEqualSplitStrategyRejects(t *testing.T) {
+	group := testGroup(3)
+	tests := []struct {
+		name      string
+		total     entity.Money
+		splitData map[entity.User]float64
+		field     string
+	}{
+		{"negative total", entity.NewMoney(-300, "USD"), nil, "expense_amount"},
+		{"zero total", entity.NewMoney(0, "USD"), nil, "expense_amount"},
+		{"outsider", entity.NewMoney(300, "USD"), map[entity.User]float64{{UserID: "stranger"}: 1}, "stranger"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewEqualSplitStrategy(group).CalculateSplits(tt.splitData, tt.total)
+			checkSplitError(t, err, tt.field)
+		})
+	}
+}
@@ -11,14 +11,37 @@ func NewExactSplitStrategy(group *entity.Group) *ExactSplitStrategy {
 		Group: group,
 	}
 }
-func (e *ExactSplitStrategy) CalculateSplits(splitData map[entity.User]float64, totalAmount float64) []*entity.Split {
+
+// CalculateSplits charges each member the amount given for them in splitData.
+// Members left out are charged nothing, and the amounts must add up to the total.
+func (e *ExactSplitStrategy) CalculateSplits(splitData map[entity.User]float64, totalAmount entity.Money) ([]*entity.Split, error) {
+	if errs := validateSplitInput(e.Group, splitData, totalAmount); len(errs) > 0 {
+		return nil, errs
+	}
+
+	errs := make(SplitErrors, 0)
 	splits := make([]*entity.Split, 0)
-	groupMembers := e.Group.GetGroupMembers()
-	for _, member := range groupMembers {
-		amount := splitData[*member]
+	sum := entity.NewMoney(0, totalAmount.Currency)
+	for _, member := range sortMembersByID(e.Group.GetGroupMembers()) {
+		value, ok := splitData[*member]
+		if !ok || value == 0 {
+			continue
+		}
+		amount, ok := moneyFromSplitValue(value, totalAmount.Currency)
+		if !ok {
+			errs = append(errs, NewSplitError(member.UserID, "has more decimal places than the currency allows"))
+			continue
+		}
 		splits = append(splits, entity.NewSplit(member, amount))
+		sum = sum.Add(amount)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if sum.Amount != totalAmount.Amount {
+		return nil, SplitErrors{NewSplitError("split_data", "amounts add up to "+sum.Decimal()+" but the expense is "+totalAmount.Decimal())}
 	}
-	return splits
+	return splits, nil
 }
 func (e *ExactSplitStrategy) GetGroup() *entity.Group {
 	return e.Group
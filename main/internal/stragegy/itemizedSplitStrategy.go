@@ -0,0 +1,160 @@
+package stragegy
+
+import (
+	"fmt"
+	"splitwise/main/internal/entity"
+)
+
+// LineItem is one line of an itemized receipt. It is split between its
+// assigned users (the whole group when none are given) with its own strategy.
+type LineItem struct {
+	Name          string                  `json:"name"`
+	UnitPrice     entity.Money            `json:"unit_price"`
+	Quantity      int64                   `json:"quantity"`
+	AssignedUsers []*entity.User          `json:"assigned_users"`
+	SplitType     SplitType               `json:"split_type"`
+	SplitData     map[entity.User]float64 `json:"-"`
+	// Splits holds the item's pre-tax splits once CalculateSplits has run
+	Splits []*entity.Split `json:"splits"`
+}
+
+func NewLineItem(name string, unitPrice entity.Money, quantity int64, assignedUsers []*entity.User, splitType SplitType, splitData map[entity.User]float64) *LineItem {
+	return &LineItem{
+		Name:          name,
+		UnitPrice:     unitPrice,
+		Quantity:      quantity,
+		AssignedUsers: assignedUsers,
+		SplitType:     splitType,
+		SplitData:     splitData,
+	}
+}
+
+func (i *LineItem) GetTotal() entity.Money {
+	return entity.NewMoney(i.UnitPrice.Amount*i.Quantity, i.UnitPrice.Currency)
+}
+
+type ItemizedSplitStrategy struct {
+	Group *entity.Group `json:"group"`
+	Items []*LineItem   `json:"items"`
+	Tax   entity.Money  `json:"tax"`
+	Tip   entity.Money  `json:"tip"`
+}
+
+func NewItemizedSplitStrategy(group *entity.Group, items []*LineItem, tax, tip entity.Money) *ItemizedSplitStrategy {
+	return &ItemizedSplitStrategy{
+		Group: group,
+		Items: items,
+		Tax:   tax,
+		Tip:   tip,
+	}
+}
+
+// GetTotal returns the receipt total: every item plus tax and tip
+func (s *ItemizedSplitStrategy) GetTotal() entity.Money {
+	total := s.Tax.Add(s.Tip)
+	for _, item := range s.Items {
+		total = total.Add(item.GetTotal())
+	}
+	return total
+}
+
+// CalculateSplits splits every item among its assigned users, then spreads tax
+// and tip over members in proportion to their item subtotal. splitData is not
+// used; each item carries its own. The result is one split per member and
+// fills in each item's Splits.
+func (s *ItemizedSplitStrategy) CalculateSplits(splitData map[entity.User]float64, totalAmount entity.Money) ([]*entity.Split, error) {
+	errs := make(SplitErrors, 0)
+	if len(s.Items) == 0 {
+		errs = append(errs, NewSplitError("items", "at least one item is required"))
+	}
+	if s.Tax.IsNegative() {
+		errs = append(errs, NewSplitError("tax", "cannot be negative"))
+	}
+	if s.Tip.IsNegative() {
+		errs = append(errs, NewSplitError("tip", "cannot be negative"))
+	}
+	for i, item := range s.Items {
+		errs = append(errs, s.calculateItemSplits(i, item)...)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if s.GetTotal().Amount != totalAmount.Amount {
+		return nil, SplitErrors{NewSplitError("expense_amount", "items, tax and tip add up to "+s.GetTotal().Decimal()+" but the expense is "+totalAmount.Decimal())}
+	}
+
+	// Item subtotals per member, in a stable member order
+	members := sortMembersByID(s.Group.GetGroupMembers())
+	subtotals := make([]int64, len(members))
+	for _, item := range s.Items {
+		for _, split := range item.Splits {
+			for m, member := range members {
+				if member.UserID == split.User.UserID {
+					subtotals[m] += split.Amount.Amount
+				}
+			}
+		}
+	}
+
+	taxShares := s.Tax.AllocateByWeights(subtotals)
+	tipShares := s.Tip.AllocateByWeights(subtotals)
+	splits := make([]*entity.Split, 0)
+	for m, member := range members {
+		amount := entity.NewMoney(subtotals[m], totalAmount.Currency).Add(taxShares[m]).Add(tipShares[m])
+		if amount.IsZero() {
+			continue
+		}
+		splits = append(splits, entity.NewSplit(member, amount))
+	}
+	return splits, nil
+}
+
+// calculateItemSplits runs the item's own strategy over its assigned users,
+// prefixing any errors with the item's position in the request
+func (s *ItemizedSplitStrategy) calculateItemSplits(index int, item *LineItem) SplitErrors {
+	field := fmt.Sprintf("items[%d]", index)
+	errs := make(SplitErrors, 0)
+	if item.Name == "" {
+		errs = append(errs, NewSplitError(field+".name", "is required"))
+	}
+	if !item.UnitPrice.IsPositive() {
+		errs = append(errs, NewSplitError(field+".unit_price", "must be greater than zero"))
+	}
+	if item.Quantity <= 0 {
+		errs = append(errs, NewSplitError(field+".quantity", "must be at least 1"))
+	}
+	assigned := item.AssignedUsers
+	if len(assigned) == 0 {
+		assigned = s.Group.GetGroupMembers()
+	}
+	for _, user := range assigned {
+		if s.Group.GetMember(user.UserID) == nil {
+			errs = append(errs, NewSplitError(field+".assigned_user_ids", user.UserID+" is not a member of this group"))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	itemGroup := entity.NewGroup(s.Group.GroupID, s.Group.GroupName, assigned)
+	strategy := GetSplitStrategy(item.SplitType, itemGroup)
+	if strategy == nil {
+		return SplitErrors{NewSplitError(field+".split_type", "is not supported for items")}
+	}
+	splits, err := strategy.CalculateSplits(item.SplitData, item.GetTotal())
+	if err != nil {
+		if itemErrs, ok := err.(SplitErrors); ok {
+			for _, itemErr := range itemErrs {
+				errs = append(errs, NewSplitError(field+"."+itemErr.Field, itemErr.Message))
+			}
+			return errs
+		}
+		return SplitErrors{NewSplitError(field, err.Error())}
+	}
+	item.Splits = splits
+	return nil
+}
+
+func (s *ItemizedSplitStrategy) GetGroup() *entity.Group {
+	return s.Group
+}
@@ -0,0 +1,102 @@
+package stragegy
+
+import (
+	"splitwise/main/internal/entity"
+	"testing"
+)
+
+func TestItemizedSplitStrategy(t *testing.T) {
+	group := testGroup(3)
+	usd := func(amount int64) entity.Money { return entity.NewMoney(amount, "USD") }
+	member := group.GetMember
+
+	tests := []struct {
+		name     string
+		items    []*LineItem
+		tax, tip entity.Money
+		want     map[string]int64
+	}{
+		{
+			name: "tax and tip by subtotal",
+			items: []*LineItem{
+				NewLineItem("pizza", usd(1200), 1, nil, Equal, nil),
+				NewLineItem("wine", usd(900), 2, []*entity.User{member("user-001"), member("user-002")}, Equal, nil),
+			},
+			tax: usd(300),
+			tip: usd(100),
+			// Subtotals 1300, 1300 and 400; the leftover tip cent goes to the first tie
+			want: map[string]int64{"user-001": 1474, "user-002": 1473, "user-003": 453},
+		},
+		{
+			name: "item with its own split",
+			items: []*LineItem{
+				NewLineItem("cake", usd(1000), 1, nil, Exact, testSplitData(group, map[string]float64{"user-001": 7.5, "user-002": 2.5})),
+			},
+			tax:  usd(0),
+			tip:  usd(0),
+			want: map[string]int64{"user-001": 750, "user-002": 250},
+		},
+		{
+			name: "members without items left out",
+			items: []*LineItem{
+				NewLineItem("coffee", usd(250), 2, []*entity.User{member("user-002")}, Equal, nil),
+			},
+			tax:  usd(0),
+			tip:  usd(50),
+			want: map[string]int64{"user-002": 550},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy := NewItemizedSplitStrategy(group, tt.items, tt.tax, tt.tip)
+			splits, err := strategy.CalculateSplits(nil, strategy.GetTotal())
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkSplits(t, splits, tt.want, "USD")
+			for _, item := range tt.items {
+				sum := int64(0)
+				for _, split := range item.Splits {
+					sum += split.Amount.Amount
+				}
+				if sum != item.GetTotal().Amount {
+					t.Errorf("%s splits sum to %d, want %d", item.Name, sum, item.GetTotal().Amount)
+				}
+			}
+		})
+	}
+}
+
+func TestItemizedSplitStrategyRejects(t *testing.T) {
+	group := testGroup(3)
+	usd := func(amount int64) entity.Money { return entity.NewMoney(amount, "USD") }
+	stranger := entity.NewUser("stranger", "stranger", "stranger@example.com")
+
+	tests := []struct {
+		name  string
+		items []*LineItem
+		tax   entity.Money
+		total entity.Money
+		field string
+	}{
+		{"no items", nil, usd(0), usd(1000), "items"},
+		{"total does not match", []*LineItem{NewLineItem("pizza", usd(1200), 1, nil, Equal, nil)}, usd(0), usd(1000), "expense_amount"},
+		{"negative tax", []*LineItem{NewLineItem("pizza", usd(1200), 1, nil, Equal, nil)}, usd(-100), usd(1100), "tax"},
+		{"no name", []*LineItem{NewLineItem("", usd(1200), 1, nil, Equal, nil)}, usd(0), usd(1200), "items[0].name"},
+		{"negative price", []*LineItem{NewLineItem("pizza", usd(-1200), 1, nil, Equal, nil)}, usd(0), usd(-1200), "items[0].unit_price"},
+		{"zero quantity", []*LineItem{NewLineItem("pizza", usd(1200), 0, nil, Equal, nil)}, usd(0), usd(0), "items[0].quantity"},
+		{"outsider", []*LineItem{NewLineItem("pizza", usd(1200), 1, []*entity.User{stranger}, Equal, nil)}, usd(0), usd(1200), "items[0].assigned_user_ids"},
+		{
+			"item percentages short of 100",
+			[]*LineItem{NewLineItem("pizza", usd(1200), 1, nil, Percentage, testSplitData(group, map[string]float64{"user-001": 50, "user-002": 40}))},
+			usd(0), usd(1200), "items[0].split_data",
+		},
+		{"unsupported item split", []*LineItem{NewLineItem("pizza", usd(1200), 1, nil, SplitType(99), nil)}, usd(0), usd(1200), "items[0].split_type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewItemizedSplitStrategy(group, tt.items, tt.tax, usd(0)).CalculateSplits(nil, tt.total)
+			checkSplitError(t, err, tt.field)
+		})
+	}
+}
@@ -1,9 +1,9 @@
 package stragegy
 
-import (
-	"math"
-	"splitwise/main/internal/entity"
-)
+import "splitwise/main/internal/entity"
+
+// Percentages are handled in basis points (hundredths of a percent)
+const fullPercentageBasisPoints = 100 * 100
 
 type PercentageSplitStrategy struct {
 	Group *entity.Group `json:"group"`
@@ -14,16 +14,45 @@ func NewPercentageSplitStrategy(group *entity.Group) *PercentageSplitStrategy {
 		Group: group,
 	}
 }
-func (p *PercentageSplitStrategy) CalculateSplits(splitData map[entity.User]float64, totalAmount float64) []*entity.Split {
+
+// CalculateSplits charges each member exactly the percentage given for them in
+// splitData. Members left out of splitData are charged nothing, and the
+// percentages must add up to 100.
+func (p *PercentageSplitStrategy) CalculateSplits(splitData map[entity.User]float64, totalAmount entity.Money) ([]*entity.Split, error) {
+	if errs := validateSplitInput(p.Group, splitData, totalAmount); len(errs) > 0 {
+		return nil, errs
+	}
+
+	errs := make(SplitErrors, 0)
+	members := make([]*entity.User, 0)
+	weights := make([]int64, 0)
+	var totalBasisPoints int64
+	for _, member := range sortMembersByID(p.Group.GetGroupMembers()) {
+		percentage, ok := splitData[*member]
+		if !ok || percentage == 0 {
+			continue
+		}
+		basisPoints, ok := hundredths(percentage)
+		if !ok {
+			errs = append(errs, NewSplitError(member.UserID, "percentage can have at most two decimal places"))
+			continue
+		}
+		members = append(members, member)
+		weights = append(weights, basisPoints)
+		totalBasisPoints += basisPoints
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if totalBasisPoints != fullPercentageBasisPoints {
+		return nil, SplitErrors{NewSplitError("split_data", "percentages must add up to 100")}
+	}
+
 	splits := make([]*entity.Split, 0)
-	groupMembers := p.Group.GetGroupMembers()
-	myPart := 100 / len(groupMembers)
-	for _, member := range groupMembers {
-		percentage := math.Abs(splitData[*member] - float64(myPart))
-		amount := totalAmount * percentage / 100
-		splits = append(splits, entity.NewSplit(member, amount))
+	for i, amount := range totalAmount.AllocateByWeights(weights) {
+		splits = append(splits, entity.NewSplit(members[i], amount))
 	}
-	return splits
+	return splits, nil
 }
 func (p *PercentageSplitStrategy) GetGroup() *entity.Group {
 	return p.Group
@@ -0,0 +1,80 @@
+package stragegy
+
+import (
+	"splitwise/main/internal/entity"
+	"testing"
+)
+
+func TestPercentageSplitStrategy(t *testing.T) {
+	tests := []struct {
+		name        string
+		total       entity.Money
+		percentages map[string]float64
+		want        map[string]int64
+	}{
+		{
+			name:        "thirds in basis points",
+			total:       entity.NewMoney(10000, "USD"),
+			percentages: map[string]float64{"user-001": 33.34, "user-002": 33.33, "user-003": 33.33},
+			want:        map[string]int64{"user-001": 3334, "user-002": 3333, "user-003": 3333},
+		},
+		{
+			name:        "remainder to largest fraction",
+			total:       entity.NewMoney(1000, "USD"),
+			percentages: map[string]float64{"user-001": 33.33, "user-002": 33.33, "user-003": 33.34},
+			want:        map[string]int64{"user-001": 333, "user-002": 333, "user-003": 334},
+		},
+		{
+			name:        "one cent halved",
+			total:       entity.NewMoney(1, "USD"),
+			percentages: map[string]float64{"user-001": 50, "user-002": 50},
+			want:        map[string]int64{"user-001": 1, "user-002": 0},
+		},
+		{
+			name:        "member left out",
+			total:       entity.NewMoney(1000, "USD"),
+			percentages: map[string]float64{"user-001": 60, "user-003": 40},
+			want:        map[string]int64{"user-001": 600, "user-003": 400},
+		},
+		{
+			name:        "zero decimal currency",
+			total:       entity.NewMoney(1000, "JPY"),
+			percentages: map[string]float64{"user-001": 12.5, "user-002": 87.5},
+			want:        map[string]int64{"user-001": 125, "user-002": 875},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group := testGroup(3)
+			splits, err := NewPercentageSplitStrategy(group).CalculateSplits(testSplitData(group, tt.percentages), tt.total)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkSplits(t, splits, tt.want, tt.total.Currency)
+		})
+	}
+}
+
+func TestPercentageSplitStrategyRejects(t *testing.T) {
+	tests := []struct {
+		name        string
+		total       entity.Money
+		percentages map[string]float64
+		field       string
+	}{
+		{"under 100", entity.NewMoney(1000, "USD"), map[string]float64{"user-001": 50, "user-002": 49.99}, "split_data"},
+		{"over 100", entity.NewMoney(1000, "USD"), map[string]float64{"user-001": 60, "user-002": 60}, "split_data"},
+		{"none given", entity.NewMoney(1000, "USD"), nil, "split_data"},
+		{"negative", entity.NewMoney(1000, "USD"), map[string]float64{"user-001": 110, "user-002": -10}, "user-002"},
+		{"three decimals", entity.NewMoney(1000, "USD"), map[string]float64{"user-001": 33.333, "user-002": 66.667}, "user-001"},
+		{"outsider", entity.NewMoney(1000, "USD"), map[string]float64{"user-001": 50, "stranger": 50}, "stranger"},
+		{"zero total", entity.NewMoney(0, "USD"), map[string]float64{"user-001": 100}, "expense_amount"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group := testGroup(3)
+			_, err := NewPercentageSplitStrategy(group).CalculateSplits(testSplitData(group, tt.percentages), tt.total)
+			checkSplitError(t, err, tt.field)
+		})
+	}
+}
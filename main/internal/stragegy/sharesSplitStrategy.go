@@ -0,0 +1,55 @@
+package stragegy
+
+import "splitwise/main/internal/entity"
+
+type SharesSplitStrategy struct {
+	Group *entity.Group `json:"group"`
+}
+
+func NewSharesSplitStrategy(group *entity.Group) *SharesSplitStrategy {
+	return &SharesSplitStrategy{
+		Group: group,
+	}
+}
+
+// CalculateSplits charges each member in proportion to their shares in
+// splitData (e.g. 2 shares vs 1 share). Members left out hold no shares. The
+// leftover minor units go to the largest fractional parts, so the splits always
+// sum to the total.
+func (s *SharesSplitStrategy) CalculateSplits(splitData map[entity.User]float64, totalAmount entity.Money) ([]*entity.Split, error) {
+	if errs := validateSplitInput(s.Group, splitData, totalAmount); len(errs) > 0 {
+		return nil, errs
+	}
+
+	errs := make(SplitErrors, 0)
+	members := make([]*entity.User, 0)
+	weights := make([]int64, 0)
+	for _, member := range sortMembersByID(s.Group.GetGroupMembers()) {
+		shares, ok := splitData[*member]
+		if !ok || shares == 0 {
+			continue
+		}
+		weight, ok := hundredths(shares)
+		if !ok {
+			errs = append(errs, NewSplitError(member.UserID, "shares can have at most two decimal places"))
+			continue
+		}
+		members = append(members, member)
+		weights = append(weights, weight)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if len(members) == 0 {
+		return nil, SplitErrors{NewSplitError("split_data", "at least one member must hold shares")}
+	}
+
+	splits := make([]*entity.Split, 0)
+	for i, amount := range totalAmount.AllocateByWeights(weights) {
+		splits = append(splits, entity.NewSplit(members[i], amount))
+	}
+	return splits, nil
+}
+func (s *SharesSplitStrategy) GetGroup() *entity.Group {
+	return s.Group
+}
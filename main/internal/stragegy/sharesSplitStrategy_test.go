@@ -0,0 +1,79 @@
+package stragegy
+
+import (
+	"splitwise/main/internal/entity"
+	"testing"
+)
+
+func TestSharesSplitStrategy(t *testing.T) {
+	tests := []struct {
+		name   string
+		total  entity.Money
+		shares map[string]float64
+		want   map[string]int64
+	}{
+		{
+			name:   "two to one",
+			total:  entity.NewMoney(1000, "USD"),
+			shares: map[string]float64{"user-001": 2, "user-002": 1},
+			want:   map[string]int64{"user-001": 667, "user-002": 333},
+		},
+		{
+			name:   "equal shares",
+			total:  entity.NewMoney(100, "USD"),
+			shares: map[string]float64{"user-001": 1, "user-002": 1, "user-003": 1},
+			want:   map[string]int64{"user-001": 34, "user-002": 33, "user-003": 33},
+		},
+		{
+			name:   "fractional shares",
+			total:  entity.NewMoney(1000, "USD"),
+			shares: map[string]float64{"user-001": 1.5, "user-003": 0.5},
+			want:   map[string]int64{"user-001": 750, "user-003": 250},
+		},
+		{
+			name:   "one cent three ways",
+			total:  entity.NewMoney(1, "USD"),
+			shares: map[string]float64{"user-001": 1, "user-002": 1, "user-003": 1},
+			want:   map[string]int64{"user-001": 1, "user-002": 0, "user-003": 0},
+		},
+		{
+			name:   "zero shares left out",
+			total:  entity.NewMoney(900, "JPY"),
+			shares: map[string]float64{"user-001": 1, "user-002": 0, "user-003": 2},
+			want:   map[string]int64{"user-001": 300, "user-003": 600},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group := testGroup(3)
+			splits, err := NewSharesSplitStrategy(group).CalculateSplits(testSplitData(group, tt.shares), tt.total)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkSplits(t, splits, tt.want, tt.total.Currency)
+		})
+	}
+}
+
+func TestSharesSplitStrategyRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		total  entity.Money
+		shares map[string]float64
+		field  string
+	}{
+		{"no shares", entity.NewMoney(1000, "USD"), nil, "split_data"},
+		{"all zero", entity.NewMoney(1000, "USD"), map[string]float64{"user-001": 0}, "split_data"},
+		{"negative", entity.NewMoney(1000, "USD"), map[string]float64{"user-001": 2, "user-002": -1}, "user-002"},
+		{"three decimals", entity.NewMoney(1000, "USD"), map[string]float64{"user-001": 1.125}, "user-001"},
+		{"outsider", entity.NewMoney(1000, "USD"), map[string]float64{"user-001": 1, "stranger": 1}, "stranger"},
+		{"negative total", entity.NewMoney(-1000, "USD"), map[string]float64{"user-001": 1}, "expense_amount"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group := testGroup(3)
+			_, err := NewSharesSplitStrategy(group).CalculateSplits(testSplitData(group, tt.shares), tt.total)
+			checkSplitError(t, err, tt.field)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package stragegy
+
+import (
+	"sort"
+	"strings"
+)
+
+// SplitError explains why the input for a split was rejected. Field names the
+// offending request field, or a user ID when one member's value is wrong.
+type SplitError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func NewSplitError(field, message string) *SplitError {
+	return &SplitError{
+		Field:   field,
+		Message: message,
+	}
+}
+
+func (e *SplitError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// SplitErrors collects every problem found in one split request
+type SplitErrors []*SplitError
+
+func (e SplitErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Fields maps each offending field to its message
+func (e SplitErrors) Fields() map[string]string {
+	fields := make(map[string]string)
+	for _, err := range e {
+		fields[err.Field] = err.Message
+	}
+	return fields
+}
+
+func (e SplitErrors) sorted() SplitErrors {
+	sort.Slice(e, func(i, j int) bool {
+		return e[i].Field < e[j].Field
+	})
+	return e
+}
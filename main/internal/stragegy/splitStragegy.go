@@ -1,7 +1,59 @@
 package stragegy
 
-import "splitwise/main/internal/entity"
+import (
+	"math"
+	"splitwise/main/internal/entity"
+)
 
+// SplitStrategy turns an expense total into per-member splits. Invalid input is
+// reported as SplitErrors rather than producing splits that don't add up.
 type SplitStrategy interface {
-	CalculateSplits(splitData map[entity.User]float64, totalAmount float64) []*entity.Split
+	CalculateSplits(splitData map[entity.User]float64, totalAmount entity.Money) ([]*entity.Split, error)
+}
+
+// validateSplitInput runs the checks shared by every strategy: a positive
+// total, and split data that only names group members with non-negative values
+func validateSplitInput(group *entity.Group, splitData map[entity.User]float64, totalAmount entity.Money) SplitErrors {
+	errs := validateSplitMembers(group, splitData, totalAmount)
+	for user, value := range splitData {
+		if value < 0 && group.GetMember(user.UserID) != nil {
+			errs = append(errs, NewSplitError(user.UserID, "cannot be negative"))
+		}
+	}
+	return errs.sorted()
+}
+
+// validateSplitMembers checks for a positive total and split data that only
+// names group members, for strategies where negative values are meaningful
+func validateSplitMembers(group *entity.Group, splitData map[entity.User]float64, totalAmount entity.Money) SplitErrors {
+	errs := make(SplitErrors, 0)
+	if !totalAmount.IsPositive() {
+		errs = append(errs, NewSplitError("expense_amount", "must be greater than zero"))
+	}
+	for user := range splitData {
+		if group.GetMember(user.UserID) == nil {
+			errs = append(errs, NewSplitError(user.UserID, "is not a member of this group"))
+		}
+	}
+	return errs.sorted()
+}
+
+// hundredths converts a value with at most two decimal places to an integer
+// count of hundredths, reporting false when it has more precision than that
+func hundredths(value float64) (int64, bool) {
+	scaled := math.Round(value * 100)
+	if math.Abs(value*100-scaled) > 1e-6 {
+		return 0, false
+	}
+	return int64(scaled), true
+}
+
+// moneyFromSplitValue converts an amount from split data to money, reporting
+// false when it has more decimal places than the currency allows
+func moneyFromSplitValue(value float64, currency entity.Currency) (entity.Money, bool) {
+	amount := entity.MoneyFromMajor(value, currency)
+	if math.Abs(amount.Major()-value) > 1e-9 {
+		return entity.Money{}, false
+	}
+	return amount, true
 }
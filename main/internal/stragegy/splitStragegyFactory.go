@@ -10,6 +10,10 @@ func GetSplitStrategy(splitType SplitType, group *entity.Group) SplitStrategy {
 		return NewPercentageSplitStrategy(group)
 	case Exact:
 		return NewExactSplitStrategy(group)
+	case Shares:
+		return NewSharesSplitStrategy(group)
+	case Adjustment:
+		return NewAdjustmentSplitStrategy(group)
 	}
 	return nil
 }
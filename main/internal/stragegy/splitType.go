@@ -6,11 +6,13 @@ const (
 	Equal SplitType = iota
 	Percentage
 	Exact
+	Shares
+	Adjustment
 )
 
 func (s SplitType) String() string {
-	return []string{"Equal", "Percentage", "Exact"}[s]
+	return []string{"Equal", "Percentage", "Exact", "Shares", "Adjustment"}[s]
 }
 func (s SplitType) GetSplitType() SplitType {
-	return []SplitType{Equal, Percentage, Exact}[s]
+	return []SplitType{Equal, Percentage, Exact, Shares, Adjustment}[s]
 }
@@ -0,0 +1,87 @@
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif" // register decoders for image.Decode
+	"image/jpeg"
+	_ "image/png"
+)
+
+// MaxPixels bounds the images Generate will decode, so a small file that
+// claims huge dimensions cannot exhaust memory
+const MaxPixels = 40_000_000
+
+var ErrTooLarge = errors.New("image dimensions are too large")
+
+// Generate decodes a JPEG, PNG or GIF image and returns a JPEG that fits
+// within size x size, keeping the aspect ratio. Smaller images are not
+// enlarged.
+func Generate(data []byte, size int) ([]byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, scale(src, size), &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// scale shrinks img to fit within size x size by averaging the source pixels
+// that fall into each destination pixel. Transparent areas become white,
+// since JPEG has no alpha channel.
+func scale(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	dstW, dstH := srcW, srcH
+	if srcW > size || srcH > size {
+		if srcW >= srcH {
+			dstW, dstH = size, max(1, srcH*size/srcW)
+		} else {
+			dstW, dstH = max(1, srcW*size/srcH), size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0 := bounds.Min.Y + y*srcH/dstH
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*srcH/dstH)
+		for x := 0; x < dstW; x++ {
+			x0 := bounds.Min.X + x*srcW/dstW
+			x1 := max(x0+1, bounds.Min.X+(x+1)*srcW/dstW)
+
+			var r, g, b, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					// Composite over white
+					white := 0xffff - uint64(ca)
+					r += uint64(cr) + white
+					g += uint64(cg) + white
+					b += uint64(cb) + white
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: 0xffff,
+			})
+		}
+	}
+	return dst
+}
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"splitwise/main/internal/api"
 	"splitwise/main/internal/auth"
+	"splitwise/main/internal/blobstore"
 	"splitwise/main/internal/db"
 )
 
@@ -21,8 +22,19 @@ func main() {
 	// Set database for auth package (persistent sessions)
 	auth.SetDB(db.DB)
 
+	// Expense attachments are kept under the data directory, next to the
+	// database unless DATA_DIR says otherwise
+	dataDir := os.Getenv("DATA_DIR")
+	if dataDir == "" {
+		dataDir = db.DataDir
+	}
+	blobs, err := blobstore.NewLocalStore(filepath.Join(dataDir, "attachments"))
+	if err != nil {
+		log.Fatal("Failed to initialize attachment storage:", err)
+	}
+
 	// Initialize API handler
-	handler := api.NewHandler()
+	handler := api.NewHandler(blobs)
 
 	// Auth routes (public)
 	http.HandleFunc("/api/auth/register", handler.EnableCORS(handler.Register))
@@ -46,6 +58,10 @@ func main() {
 	}))
 	http.HandleFunc("/api/groups/details", handler.EnableCORS(handler.GetGroupDetails))
 	http.HandleFunc("/api/groups/add-member", handler.EnableCORS(handler.AddMemberToGroup))
+	http.HandleFunc("/api/groups/settings", handler.EnableCORS(handler.UpdateGroupSettings))
+	http.HandleFunc("/api/groups/activity", handler.EnableCORS(handler.GetGroupActivity))
+	http.HandleFunc("/api/groups/categories", handler.EnableCORS(handler.GroupCategories))
+	http.HandleFunc("/api/categories/suggest", handler.EnableCORS(handler.SuggestCategory))
 
 	// Expense routes (protected)
 	http.HandleFunc("/api/expenses", handler.EnableCORS(func(w http.ResponseWriter, r *http.Request) {
@@ -54,6 +70,20 @@ func main() {
 			handler.GetGroupExpenses(w, r)
 		case http.MethodPost:
 			handler.AddExpense(w, r)
+		case http.MethodDelete:
+			handler.DeleteExpense(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
+	http.HandleFunc("/api/expenses/attachments", handler.EnableCORS(handler.ExpenseAttachments))
+	http.HandleFunc("/api/attachments/download", handler.EnableCORS(handler.DownloadAttachment))
+	http.HandleFunc("/api/expenses/deleted", handler.EnableCORS(handler.GetDeletedExpenses))
+	http.HandleFunc("/api/expenses/restore", handler.EnableCORS(handler.RestoreExpense))
+	http.HandleFunc("/api/expenses/", handler.EnableCORS(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			handler.UpdateExpense(w, r)
 		default:
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
@@ -62,7 +92,30 @@ func main() {
 	// Balance routes (protected)
 	http.HandleFunc("/api/balances", handler.EnableCORS(handler.GetGroupBalances))
 	http.HandleFunc("/api/balances/summary", handler.EnableCORS(handler.GetMyBalanceSummary))
+	http.HandleFunc("/api/balances/simplified", handler.EnableCORS(handler.GetSimplifiedBalances))
+	http.HandleFunc("/api/balances/net", handler.EnableCORS(handler.GetMyNetBalances))
 	http.HandleFunc("/api/settle", handler.EnableCORS(handler.Settle))
+	http.HandleFunc("/api/settle/net", handler.EnableCORS(handler.SettleNet))
+	http.HandleFunc("/api/settlements", handler.EnableCORS(handler.GetSettlements))
+	http.HandleFunc("/api/settlements/pending", handler.EnableCORS(handler.GetPendingSettlements))
+	http.HandleFunc("/api/settlements/confirm", handler.EnableCORS(handler.ConfirmSettlement))
+	http.HandleFunc("/api/settlements/dispute", handler.EnableCORS(handler.DisputeSettlement))
+
+	// Recurring expense routes
+	http.HandleFunc("/api/recurring", handler.EnableCORS(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.GetRecurringExpenses(w, r)
+		case http.MethodPost:
+			handler.CreateRecurringExpense(w, r)
+		case http.MethodPut:
+			handler.UpdateRecurringExpense(w, r)
+		case http.MethodDelete:
+			handler.DeleteRecurringExpense(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}))
 
 	// Admin routes
 	http.HandleFunc("/api/admin/login", handler.EnableCORS(handler.AdminLogin))
@@ -70,6 +123,9 @@ func main() {
 	http.HandleFunc("/api/admin/groups", handler.EnableCORS(handler.AdminGetGroups))
 	http.HandleFunc("/api/admin/users/delete", handler.EnableCORS(handler.AdminDeleteUser))
 	http.HandleFunc("/api/admin/groups/delete", handler.EnableCORS(handler.AdminDeleteGroup))
+	http.HandleFunc("/api/admin/balances/drift", handler.EnableCORS(handler.AdminBalanceDrift))
+	http.HandleFunc("/api/admin/exchange-rates", handler.EnableCORS(handler.AdminExchangeRates))
+	http.HandleFunc("/api/admin/exchange-rates/import", handler.EnableCORS(handler.AdminImportExchangeRates))
 
 	// Serve static files (web UI)
 	// Try multiple paths to find the web directory
@@ -93,6 +149,9 @@ func main() {
 		port = "8080"
 	}
 
+	// Create recurring expenses as they fall due
+	handler.StartRecurringScheduler()
+
 	fmt.Printf("🚀 SplitWise server running on port %s\n", port)
 	log.Fatal(http.ListenAndServe(":"+port, nil))
 }